@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+const usage = `usage: mosaic <command> [flags] <arg>...
+
+commands:
+  serve    serve pictures over http, args are config paths
+  sign     print signed urls, args are paths or urls
+  validate check configs and report every error, args are config paths
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "serve":
+		err = serve(os.Args[2:])
+	case "sign":
+		err = sign(os.Args[2:])
+	case "validate":
+		err = validate(os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
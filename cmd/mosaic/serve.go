@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/ueef/mosaic/pkg/cache"
+	"github.com/ueef/mosaic/pkg/config"
+	"github.com/ueef/mosaic/pkg/dispatcher"
+	"github.com/ueef/mosaic/pkg/server"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func serve(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("listen", ":8080", "an address to listen on")
+	ql := fs.Int("queue", 16, "a number of workers and a length of queues of every stage")
+	cl := fs.Int("cache", 64<<20, "a size of the memory cache in bytes")
+	dd := fs.String("disk-cache", "", "a dir of the disk cache, the disk cache is disabled if empty")
+	dl := fs.String("disk-cache-layout", "direct", "a layout of the disk cache, direct or hashed")
+	dt := fs.Duration("disk-cache-ttl", 0, "a time entries of the disk cache are valid, forever if zero")
+	st := fs.Duration("shutdown-timeout", 30*time.Second, "a time given to in-flight requests on shutdown")
+	sm := fs.Bool("strict", false, "fail on keys no picture, loader, saver, encoder or filter looks up")
+	wi := fs.Duration("watch", 0, "an interval of checking configs for changes, configs are reloaded on SIGHUP only if zero")
+	_ = fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		return errors.New("at least one config path is required")
+	}
+
+	stamp, err := config.Stamp(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	p, err := config.Parse(fs.Args(), *sm)
+	if err != nil {
+		return err
+	}
+
+	c := cache.Tiered{}
+	if *cl > 0 {
+		c = append(c, cache.NewMemory(*cl))
+	}
+
+	if *dd != "" {
+		switch *dl {
+		case "direct":
+			c = append(c, cache.NewDirectDisk(*dd, *dt))
+		case "hashed":
+			c = append(c, cache.NewHashedDisk(*dd, *dt))
+		default:
+			return fmt.Errorf("a layout of the disk cache \"%s\" is undefined", *dl)
+		}
+	}
+
+	d := dispatcher.NewDispatcher(p, c)
+	err = d.Start(*ql)
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{
+		Addr:    *addr,
+		Handler: server.NewHandler(d),
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.ListenAndServe()
+	}()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sig)
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var tick <-chan time.Time
+	if *wi > 0 {
+		t := time.NewTicker(*wi)
+		defer t.Stop()
+		tick = t.C
+	}
+
+loop:
+	for {
+		select {
+		case err = <-errs:
+			return err
+		case <-sig:
+			break loop
+		case <-hup:
+			stamp = reload(d, fs.Args(), *sm, "")
+		case <-tick:
+			stamp = reload(d, fs.Args(), *sm, stamp)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *st)
+	defer cancel()
+
+	err = srv.Shutdown(ctx)
+	if err != nil {
+		return err
+	}
+
+	return d.Stop(ctx)
+}
+
+func reload(d *dispatcher.Dispatcher, paths []string, strict bool, stamp string) string {
+	s, err := config.Stamp(paths)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "reload:", err)
+		return stamp
+	}
+	if s == stamp {
+		return stamp
+	}
+
+	p, err := config.Parse(paths, strict)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "reload:", err)
+		return s
+	}
+
+	d.Reload(p)
+	fmt.Fprintf(os.Stderr, "reload: %d pictures\n", len(p))
+
+	return s
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/ueef/mosaic/pkg/signature"
+	"net/url"
+	"time"
+)
+
+func sign(args []string) error {
+	fs := flag.NewFlagSet("sign", flag.ExitOnError)
+	key := fs.String("key", "", "a key to sign with")
+	alg := fs.String("algorithm", signature.AlgorithmSha256, "an algorithm of the signature, sha1, sha256 or sha512")
+	loc := fs.String("location", signature.LocationQuery, "a location of the signature, query or path")
+	ttl := fs.Duration("ttl", 0, "a time the url is valid, forever if zero")
+	_ = fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		return errors.New("at least one path is required")
+	}
+
+	s, err := signature.New([]string{*key}, *alg, *loc)
+	if err != nil {
+		return err
+	}
+
+	var e time.Time
+	if *ttl > 0 {
+		e = time.Now().Add(*ttl)
+	}
+
+	for _, a := range fs.Args() {
+		u, err := url.Parse(a)
+		if err != nil {
+			return err
+		}
+
+		p := s.Sign(u.Path, u.Query(), e)
+		if u.Host != "" {
+			p = u.Scheme + "://" + u.Host + p
+		}
+		fmt.Println(p)
+	}
+
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/ueef/mosaic/pkg/config"
+	"os"
+	"path/filepath"
+)
+
+func validate(args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	st := fs.Bool("strict", true, "report keys no picture, loader, saver, encoder or filter looks up")
+	_ = fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		return errors.New("at least one config path is required")
+	}
+
+	p, errs := config.Validate(fs.Args(), *st)
+	for _, path := range fs.Args() {
+		if m, err := filepath.Glob(path); err == nil && len(m) == 0 {
+			errs = append(errs, &config.Error{File: path, Err: errors.New("no files match the path")})
+		}
+	}
+
+	for _, err := range errs {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%d errors found", len(errs))
+	}
+
+	fmt.Printf("%d pictures are valid\n", len(p))
+
+	return nil
+}
@@ -0,0 +1,19 @@
+package cache
+
+import "time"
+
+// Cache gets entries along with the time they expire at, the zero time for
+// entries that never do.
+type Cache interface {
+	Get(key string) ([]byte, time.Time, bool)
+	Set(key string, buff []byte, ttl time.Duration)
+}
+
+type Stats struct {
+	Hits        uint64
+	Misses      uint64
+	Evictions   uint64
+	Expirations uint64
+	Entries     int
+	Size        int
+}
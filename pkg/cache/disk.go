@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"github.com/ueef/mosaic/pkg/saver"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Disk lays out files the same way savers do. Entries expire by the modification
+// time of their files after ttl, per-entry ttls are ignored.
+type Disk struct {
+	p   func(key string) (string, error)
+	ttl time.Duration
+}
+
+func (c *Disk) Get(k string) ([]byte, time.Time, bool) {
+	p, err := c.p(k)
+	if err != nil {
+		return nil, time.Time{}, false
+	}
+
+	var e time.Time
+	if c.ttl > 0 {
+		i, err := os.Stat(p)
+		if err != nil {
+			return nil, time.Time{}, false
+		}
+
+		e = i.ModTime().Add(c.ttl)
+		if time.Now().After(e) {
+			return nil, time.Time{}, false
+		}
+	}
+
+	b, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil, time.Time{}, false
+	}
+
+	return b, e, true
+}
+
+func (c *Disk) Set(k string, b []byte, _ time.Duration) {
+	p, err := c.p(k)
+	if err != nil {
+		return
+	}
+
+	d := filepath.Dir(p)
+	err = os.MkdirAll(d, 0755)
+	if err != nil {
+		return
+	}
+
+	f, err := ioutil.TempFile(d, ".mosaic-")
+	if err != nil {
+		return
+	}
+
+	_, err = f.Write(b)
+	if err == nil {
+		err = f.Chmod(0644)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), p)
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+	}
+}
+
+func NewDisk(p func(key string) (string, error), ttl time.Duration) *Disk {
+	return &Disk{
+		p:   p,
+		ttl: ttl,
+	}
+}
+
+func NewDirectDisk(dir string, ttl time.Duration) *Disk {
+	return NewDisk(func(k string) (string, error) {
+		return saver.Contain(dir, k)
+	}, ttl)
+}
+
+func NewHashedDisk(dir string, ttl time.Duration) *Disk {
+	return NewDisk(saver.NewHashed(dir).GetFilePath, ttl)
+}
@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+type entry struct {
+	k string
+	b []byte
+	e time.Time
+}
+
+type Memory struct {
+	m sync.Mutex
+	q *list.List
+	i map[string]*list.Element
+	n int
+	l int
+	s Stats
+}
+
+func (c *Memory) Get(k string) ([]byte, time.Time, bool) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	el, ok := c.i[k]
+	if !ok {
+		c.s.Misses++
+		return nil, time.Time{}, false
+	}
+
+	e := el.Value.(*entry)
+	if !e.e.IsZero() && time.Now().After(e.e) {
+		c.remove(el)
+		c.s.Expirations++
+		c.s.Misses++
+		return nil, time.Time{}, false
+	}
+
+	c.q.MoveToFront(el)
+	c.s.Hits++
+
+	return e.b, e.e, true
+}
+
+func (c *Memory) Set(k string, b []byte, ttl time.Duration) {
+	if len(b) > c.l {
+		return
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	var e time.Time
+	if ttl > 0 {
+		e = time.Now().Add(ttl)
+	}
+
+	if el, ok := c.i[k]; ok {
+		c.remove(el)
+	}
+
+	c.i[k] = c.q.PushFront(&entry{k, b, e})
+	c.n += len(b)
+
+	for c.n > c.l {
+		c.remove(c.q.Back())
+		c.s.Evictions++
+	}
+}
+
+func (c *Memory) Stats() Stats {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	s := c.s
+	s.Entries = c.q.Len()
+	s.Size = c.n
+
+	return s
+}
+
+func (c *Memory) remove(el *list.Element) {
+	e := c.q.Remove(el).(*entry)
+	delete(c.i, e.k)
+	c.n -= len(e.b)
+}
+
+func NewMemory(l int) *Memory {
+	return &Memory{
+		m: sync.Mutex{},
+		q: list.New(),
+		i: map[string]*list.Element{},
+		n: 0,
+		l: l,
+	}
+}
@@ -0,0 +1,40 @@
+package cache
+
+import "time"
+
+type Tiered []Cache
+
+func (c Tiered) Get(k string) ([]byte, time.Time, bool) {
+	for i := range c {
+		b, e, ok := c[i].Get(k)
+		if !ok {
+			continue
+		}
+
+		var ttl time.Duration
+		if !e.IsZero() {
+			ttl = time.Until(e)
+			if ttl <= 0 {
+				return b, e, true
+			}
+		}
+
+		for j := 0; j < i; j++ {
+			c[j].Set(k, b, ttl)
+		}
+
+		return b, e, true
+	}
+
+	return nil, time.Time{}, false
+}
+
+func (c Tiered) Set(k string, b []byte, ttl time.Duration) {
+	for i := range c {
+		c[i].Set(k, b, ttl)
+	}
+}
+
+func NewTiered(c ...Cache) Tiered {
+	return Tiered(c)
+}
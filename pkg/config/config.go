@@ -1,46 +1,106 @@
 package config
 
 import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"github.com/ueef/mosaic/pkg/parse"
 	"github.com/ueef/mosaic/pkg/picture"
 	"gopkg.in/yaml.v3"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 )
 
+var tracking sync.Mutex
+
+type Error struct {
+	File   string
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *Error) Error() string {
+	if e.Line == 0 {
+		return e.File + ": " + e.Err.Error()
+	}
+
+	return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
 func ParsePath(path string) (picture.Pictures, error) {
-	pics := picture.Pictures{}
-	paths, err := filepath.Glob(path)
-	if err != nil {
-		return nil, err
+	return ParsePaths([]string{path})
+}
+
+func ParsePaths(paths []string) (picture.Pictures, error) {
+	return Parse(paths, false)
+}
+
+// Parse parses configs, failing in the strict mode on keys no constructor
+// looks up, which are typos or leftovers most likely.
+func Parse(paths []string, strict bool) (picture.Pictures, error) {
+	pics, errs := Validate(paths, strict)
+	if len(errs) > 0 {
+		return nil, errs[0]
 	}
 
+	return pics, nil
+}
+
+// Validate parses configs like ParsePaths, but goes on after an invalid
+// picture or file and returns every error, each one located by an *Error.
+func Validate(paths []string, strict bool) (picture.Pictures, []error) {
+	var errs []error
+
+	pics := picture.Pictures{}
 	for _, path := range paths {
-		p, err := parseFile(path)
+		ps, err := filepath.Glob(path)
 		if err != nil {
-			return nil, err
+			errs = append(errs, &Error{File: path, Err: err})
+			continue
 		}
 
-		pics = merge(pics, p)
+		for _, path := range ps {
+			p, es := parseFile(path, strict)
+			pics = merge(pics, p)
+			errs = append(errs, es...)
+		}
 	}
 
-	return pics, nil
+	return pics, errs
 }
 
-func ParsePaths(paths []string) (picture.Pictures, error) {
-	pics := picture.Pictures{}
+func Stamp(paths []string) (string, error) {
+	h := sha1.New()
 	for _, path := range paths {
-		p, err := ParsePath(path)
+		ps, err := filepath.Glob(path)
 		if err != nil {
-			return nil, err
+			return "", err
 		}
 
-		pics = merge(pics, p)
+		for _, p := range ps {
+			fi, err := os.Stat(p)
+			if err != nil {
+				return "", err
+			}
+
+			fmt.Fprintf(h, "%s %d %d\n", p, fi.Size(), fi.ModTime().UnixNano())
+		}
 	}
 
-	return pics, nil
+	return hex.EncodeToString(h.Sum(nil)), nil
 }
 
 func merge(a, b picture.Pictures) picture.Pictures {
@@ -51,46 +111,148 @@ func merge(a, b picture.Pictures) picture.Pictures {
 	return c
 }
 
-func parseFile(path string) (picture.Pictures, error) {
-	f, err := os.Open(path)
+func parseFile(path string, strict bool) (picture.Pictures, []error) {
+	buf, err := ioutil.ReadFile(path)
 	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
-
-	buf, err := ioutil.ReadAll(f)
-	if err != nil {
-		return nil, err
+		return nil, []error{&Error{File: path, Err: err}}
 	}
 
+	c := struct{ Pictures []interface{} }{}
+	n := &yaml.Node{}
 	switch filepath.Ext(path) {
 	case ".json":
-		return parseJson(buf)
-	case ".yml":
-		fallthrough
-	case ".yaml":
-		return parseYaml(buf)
+		err = json.Unmarshal(buf, &c)
+		if err != nil {
+			e := &Error{File: path, Err: err}
+			e.Line, e.Column = position(buf, offset(err))
+			return nil, []error{e}
+		}
+		if yaml.Unmarshal(buf, n) != nil {
+			n = nil
+		}
+	case ".yml", ".yaml":
+		err = yaml.Unmarshal(buf, n)
+		if err == nil {
+			err = n.Decode(&c)
+		}
+		if err != nil {
+			return nil, []error{&Error{File: path, Err: err}}
+		}
 	default:
-		return nil, errors.New(path + " has an unsupported type")
+		return nil, []error{&Error{File: path, Err: errors.New("the file has an unsupported type")}}
 	}
+
+	var errs []error
+	pics := make(picture.Pictures, 0, len(c.Pictures))
+	for i := range c.Pictures {
+		p, es := parsePicture(c.Pictures[i], strict)
+		for _, err := range es {
+			err = parse.Prefix(err, "pictures", parse.Index(i))
+			e := &Error{File: path, Err: err}
+			if n != nil {
+				e.Line, e.Column = locate(n, err.(*parse.Error).Path)
+			}
+			errs = append(errs, e)
+		}
+		if len(es) == 0 {
+			pics = append(pics, p)
+		}
+	}
+	sort.SliceStable(errs, func(i, j int) bool {
+		a, b := errs[i].(*Error), errs[j].(*Error)
+		return a.Line < b.Line || (a.Line == b.Line && a.Column < b.Column)
+	})
+
+	return pics, errs
 }
 
-func parseJson(d []byte) (picture.Pictures, error) {
-	c := struct{ Pictures []interface{} }{}
-	err := json.Unmarshal(d, &c)
+func parsePicture(c interface{}, strict bool) (*picture.Picture, []error) {
+	if !strict {
+		p, err := picture.NewPictureFromConfig(c)
+		if err != nil {
+			return nil, split(err)
+		}
+
+		return p, nil
+	}
+
+	tracking.Lock()
+	defer tracking.Unlock()
+
+	u := parse.Track()
+	p, err := picture.NewPictureFromConfig(c)
+	u.Stop()
 	if err != nil {
-		return nil, err
+		return nil, split(err)
 	}
 
-	return picture.NewPicturesFromConfig(c.Pictures)
+	return p, u.Check(c)
 }
 
-func parseYaml(d []byte) (picture.Pictures, error) {
-	c := struct{ Pictures []interface{} }{}
-	err := yaml.Unmarshal(d, &c)
-	if err != nil {
-		return nil, err
+func split(err error) []error {
+	if es, ok := err.(parse.Errors); ok {
+		return es
+	}
+
+	return []error{err}
+}
+
+func locate(n *yaml.Node, path []string) (int, int) {
+	if n.Kind == yaml.DocumentNode && len(n.Content) > 0 {
+		n = n.Content[0]
+	}
+
+	for _, p := range path {
+		for n.Kind == yaml.AliasNode && n.Alias != nil {
+			n = n.Alias
+		}
+
+		c := child(n, p)
+		if c == nil {
+			break
+		}
+		n = c
+	}
+
+	return n.Line, n.Column
+}
+
+func child(n *yaml.Node, p string) *yaml.Node {
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if strings.EqualFold(n.Content[i].Value, p) {
+				return n.Content[i+1]
+			}
+		}
+	case yaml.SequenceNode:
+		i, err := strconv.Atoi(strings.Trim(p, "[]"))
+		if err == nil && strings.HasPrefix(p, "[") && i < len(n.Content) {
+			return n.Content[i]
+		}
 	}
 
-	return picture.NewPicturesFromConfig(c.Pictures)
+	return nil
+}
+
+func offset(err error) int64 {
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		return e.Offset
+	case *json.UnmarshalTypeError:
+		return e.Offset
+	}
+
+	return -1
+}
+
+func position(b []byte, o int64) (int, int) {
+	if o < 0 || o > int64(len(b)) {
+		return 0, 0
+	}
+
+	l := bytes.Count(b[:o], []byte("\n"))
+	c := o - int64(bytes.LastIndexByte(b[:o], '\n'))
+
+	return l + 1, int(c)
 }
@@ -0,0 +1,119 @@
+package dispatcher
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/ueef/mosaic/pkg/encoder"
+	"github.com/ueef/mosaic/pkg/picture"
+	"image"
+	"image/draw"
+	"image/gif"
+)
+
+// maxComposedPixels bounds the pixels of frames composed out of an animation,
+// 4 bytes each, so a small gif of many frames or of a huge canvas can't
+// exhaust memory.
+const maxComposedPixels = 1 << 27
+
+var ErrTooLarge = errors.New("an animation is too large to compose")
+
+func decode(b []byte, frame int) (image.Image, *encoder.Animation, error) {
+	if !bytes.HasPrefix(b, []byte("GIF8")) {
+		b, inv := markAdobe(b)
+		img, _, err := image.Decode(bytes.NewReader(b))
+		if err == nil && inv {
+			invertCMYK(img)
+		}
+
+		return img, nil, err
+	}
+
+	g, err := gif.DecodeAll(bytes.NewReader(b))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if frame >= len(g.Image) {
+		return nil, nil, fmt.Errorf("a frame %d is out of range, the image has %d frames", frame, len(g.Image))
+	}
+
+	if len(g.Image) == 1 {
+		return g.Image[0], nil, nil
+	}
+
+	f, err := composeFrames(g, frame)
+	if err != nil {
+		return nil, nil, err
+	}
+	if frame != picture.AllFrames {
+		return f[0], nil, nil
+	}
+
+	d := g.Disposal
+	if len(d) != len(f) {
+		d = nil
+	}
+
+	return f[0], &encoder.Animation{
+		Frames:    f,
+		Delays:    g.Delay,
+		Disposals: d,
+		LoopCount: g.LoopCount,
+	}, nil
+}
+
+// composeFrames draws frames of g over each other, returning either all of
+// them or only the one at the index frame.
+func composeFrames(g *gif.GIF, frame int) ([]image.Image, error) {
+	b := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	for _, f := range g.Image {
+		b = b.Union(f.Bounds())
+	}
+
+	n := len(g.Image)
+	if frame != picture.AllFrames {
+		n = 1
+	}
+	if int64(n+2)*int64(b.Dx())*int64(b.Dy()) > maxComposedPixels {
+		return nil, ErrTooLarge
+	}
+
+	c := image.NewRGBA(b)
+	f := make([]image.Image, 0, n)
+	for i, p := range g.Image {
+		var d byte
+		if i < len(g.Disposal) {
+			d = g.Disposal[i]
+		}
+
+		var prev *image.RGBA
+		if d == gif.DisposalPrevious {
+			prev = cloneRGBA(c)
+		}
+
+		draw.Draw(c, p.Bounds(), p, p.Bounds().Min, draw.Over)
+		if i == frame {
+			return append(f, c), nil
+		}
+		if frame == picture.AllFrames {
+			f = append(f, cloneRGBA(c))
+		}
+
+		switch d {
+		case gif.DisposalBackground:
+			draw.Draw(c, p.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			c = prev
+		}
+	}
+
+	return f, nil
+}
+
+func cloneRGBA(src *image.RGBA) *image.RGBA {
+	dst := image.NewRGBA(src.Rect)
+	copy(dst.Pix, src.Pix)
+
+	return dst
+}
@@ -1,51 +1,124 @@
 package dispatcher
 
-import "sync"
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type awaiter struct {
+	c      []chan *Response
+	ctx    context.Context
+	cancel context.CancelFunc
+}
 
 type awaiters struct {
 	m sync.Mutex
-	r map[string][]chan *Response
+	r map[string]*awaiter
 }
 
-func (a *awaiters) pop(k string) chan *Response {
+func (a *awaiters) cancel() {
 	a.m.Lock()
 	defer a.m.Unlock()
 
-	r, ok := a.r[k]
-	if !ok {
-		return nil
+	for _, w := range a.r {
+		w.cancel()
 	}
+}
 
-	c := r[0]
-	r = r[1:]
+func (a *awaiters) pop(k string, w *awaiter) []chan *Response {
+	a.m.Lock()
+	defer a.m.Unlock()
 
-	if len(r) > 0 {
-		a.r[k] = r
-	} else {
+	if a.r[k] == w {
 		delete(a.r, k)
 	}
 
+	c := w.c
+	w.c = nil
+	w.cancel()
+
 	return c
 }
 
-func (a *awaiters) push(p string, c chan *Response) bool {
+func (a *awaiters) push(k string, c chan *Response, t time.Duration) (*awaiter, bool) {
 	a.m.Lock()
 	defer a.m.Unlock()
 
-	r, ok := a.r[p]
+	w, ok := a.r[k]
 	if ok {
-		r = append(r, c)
+		w.c = append(w.c, c)
+		return w, false
+	}
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if t > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), t)
 	} else {
-		r = []chan *Response{c}
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+
+	w = &awaiter{
+		c:      []chan *Response{c},
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	a.r[k] = w
+
+	return w, true
+}
+
+func (a *awaiters) remove(k string, w *awaiter, c chan *Response) bool {
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	for i := range w.c {
+		if w.c[i] != c {
+			continue
+		}
+
+		w.c = append(w.c[:i], w.c[i+1:]...)
+		if len(w.c) == 0 {
+			w.cancel()
+			if a.r[k] == w {
+				delete(a.r, k)
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// drop removes c from w, telling if nobody awaits w anymore, in which case
+// w is removed too.
+func (a *awaiters) drop(k string, w *awaiter, c chan *Response) bool {
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	for i := range w.c {
+		if w.c[i] == c {
+			w.c = append(w.c[:i], w.c[i+1:]...)
+			break
+		}
+	}
+	if len(w.c) > 0 {
+		return false
+	}
+
+	w.cancel()
+	if a.r[k] == w {
+		delete(a.r, k)
 	}
-	a.r[p] = r
 
-	return !ok
+	return true
 }
 
 func newAwaiters() *awaiters {
 	return &awaiters{
 		m: sync.Mutex{},
-		r: map[string][]chan *Response{},
+		r: map[string]*awaiter{},
 	}
 }
@@ -0,0 +1,63 @@
+package dispatcher
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+)
+
+var adobeMarker = []byte{0xff, 0xee, 0x00, 0x0e, 'A', 'd', 'o', 'b', 'e', 0x00, 0x64, 0x00, 0x00, 0x00, 0x00, 0x00}
+
+// markAdobe adds an Adobe APP14 marker to 4-component jpegs lacking one, as
+// image/jpeg refuses to decode them otherwise. Such images store plain CMYK,
+// so the result must be inverted back after decoding.
+func markAdobe(b []byte) ([]byte, bool) {
+	if !bytes.HasPrefix(b, []byte{0xff, 0xd8}) {
+		return b, false
+	}
+
+	var n int
+	p := 2
+	for p+4 <= len(b) && b[p] == 0xff {
+		m := b[p+1]
+		if m == 0xda || m == 0xd9 {
+			break
+		}
+
+		l := int(binary.BigEndian.Uint16(b[p+2:]))
+		if l < 2 || p+2+l > len(b) {
+			break
+		}
+
+		s := b[p+4 : p+2+l]
+		switch {
+		case m == 0xee && bytes.HasPrefix(s, []byte("Adobe")):
+			return b, false
+		case m >= 0xc0 && m <= 0xcf && m != 0xc4 && m != 0xc8 && m != 0xcc && len(s) >= 6:
+			n = int(s[5])
+		}
+		p += 2 + l
+	}
+
+	if n != 4 {
+		return b, false
+	}
+
+	c := make([]byte, 0, len(b)+len(adobeMarker))
+	c = append(c, b[:2]...)
+	c = append(c, adobeMarker...)
+	c = append(c, b[2:]...)
+
+	return c, true
+}
+
+func invertCMYK(i image.Image) {
+	c, ok := i.(*image.CMYK)
+	if !ok {
+		return
+	}
+
+	for k := range c.Pix {
+		c.Pix[k] = 255 - c.Pix[k]
+	}
+}
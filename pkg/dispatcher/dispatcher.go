@@ -1,15 +1,28 @@
 package dispatcher
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"github.com/ueef/mosaic/pkg/cache"
 	"github.com/ueef/mosaic/pkg/picture"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
+var ErrStopped = errors.New("the dispatcher is stopped")
+
 type Dispatcher struct {
-	c  cache
+	m  sync.RWMutex
+	c  cache.Cache
 	a  awaiters
 	p  picture.Pictures
+	v  atomic.Value
 	s  bool
+	q  bool
+	w  sync.WaitGroup
+	j  sync.WaitGroup
 	ch struct {
 		s chan *Response
 		l chan *Response
@@ -19,36 +32,188 @@ type Dispatcher struct {
 }
 
 func (d *Dispatcher) Dispatch(host, path string) (<-chan *Response, error) {
-	if !d.s {
-		return nil, fmt.Errorf("the dispatcher must be started before use")
+	return d.DispatchContext(context.Background(), host, path)
+}
+
+func (d *Dispatcher) DispatchContext(ctx context.Context, host, path string) (<-chan *Response, error) {
+	return d.DispatchRequest(ctx, Request{Host: host, Path: path})
+}
+
+func (d *Dispatcher) DispatchRequest(ctx context.Context, req Request) (<-chan *Response, error) {
+	d.m.RLock()
+	p := d.p
+	d.m.RUnlock()
+
+	pict, err := p.Match(req.Host, req.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ctx.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	if pict.Signer != nil {
+		req.Path, err = pict.Signer.Verify(req.Path, req.Query)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	pict, err := d.p.Match(host, path)
+	params := pict.Params(req.Host, req.Path)
+	pict, err = pict.Resolve(params)
 	if err != nil {
 		return nil, err
 	}
 
+	pict, err = pict.Transform(req.Query, params)
+	if err != nil {
+		return nil, err
+	}
+
+	r := NewResponse(req.Path, pict)
+	r.Enc = pict.Negotiate(req.Accept)
+	r.Key = pict.Key(req.Host, req.Path, r.Enc)
+	r.id = pict.Version + r.Key
+	r.Params = params
+
 	c := make(chan *Response, 1)
-	if d.a.push(path, c) {
-		if r := d.c.get(path); r != nil {
-			d.ch.r <- r
+	w, err := d.enqueue(ctx, r, c)
+	if err != nil {
+		return nil, err
+	}
+
+	o := make(chan *Response, 1)
+	go func() {
+		defer close(o)
+
+		var err error
+		select {
+		case res := <-c:
+			o <- res
+			return
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-w.ctx.Done():
+			err = w.ctx.Err()
+		}
+
+		if d.a.remove(r.id, w, c) {
+			e := NewErrorResponse(req.Path, err, NewTimer())
+			e.Key = r.Key
+			e.id = r.id
+			e.Enc = r.Enc
+			o <- e
 		} else {
-			d.ch.l <- NewResponse(path, pict)
+			o <- <-c
+		}
+	}()
+
+	return o, nil
+}
+
+// Reload swaps the pictures. Renditions of pictures that weren't defined the
+// same way before are no longer looked up in savers, which still hold the
+// ones of former definitions under the same keys, and get overwritten.
+func (d *Dispatcher) Reload(p picture.Pictures) {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	o := map[string]bool{}
+	for i := range d.p {
+		o[d.p[i].Version] = true
+	}
+
+	v, _ := d.v.Load().(map[string]bool)
+	n := make(map[string]bool, len(v))
+	for k := range v {
+		n[k] = true
+	}
+	for i := range p {
+		if !o[p[i].Version] {
+			n[p[i].Version] = true
 		}
 	}
 
-	return c, nil
+	d.v.Store(n)
+	d.p = p
+}
+
+func (d *Dispatcher) stale(version string) bool {
+	v, _ := d.v.Load().(map[string]bool)
+
+	return v[version]
+}
+
+// enqueue holds d.m only to register a job, so that Reload and Stop never
+// wait on a full queue.
+func (d *Dispatcher) enqueue(ctx context.Context, r *Response, c chan *Response) (*awaiter, error) {
+	d.m.RLock()
+	if !d.s {
+		d.m.RUnlock()
+		return nil, fmt.Errorf("the dispatcher must be started before use")
+	}
+	if d.q {
+		d.m.RUnlock()
+		return nil, ErrStopped
+	}
+
+	w, ok := d.a.push(r.id, c, r.Pict.Timeout)
+	if ok {
+		d.j.Add(1)
+	}
+	d.m.RUnlock()
+
+	if !ok {
+		return w, nil
+	}
+
+	r.a = w
+	ch := d.ch.l
+	if b, ok := d.get(r.id); ok {
+		r.Buff = b
+		ch = d.ch.r
+	}
+
+	var err error
+	select {
+	case ch <- r:
+		return w, nil
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-w.ctx.Done():
+		err = w.ctx.Err()
+	}
+
+	if d.a.drop(r.id, w, c) {
+		d.j.Done()
+	} else {
+		// requests joined meanwhile still await the job
+		go func() {
+			ch <- r
+		}()
+	}
+
+	return nil, err
 }
 
-func (d *Dispatcher) Start(ql, cl int) error {
+func (d *Dispatcher) Start(ql int) error {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	if d.s {
+		return fmt.Errorf("the dispatcher is already started")
+	}
+
 	d.s = true
-	d.c = *newCache(cl)
 	d.a = *newAwaiters()
 	d.ch.s = make(chan *Response, ql)
 	d.ch.l = make(chan *Response, ql)
 	d.ch.p = make(chan *Response, ql)
 	d.ch.r = make(chan *Response, ql)
 
+	d.w.Add(ql * 4)
 	for i := 0; i < ql; i++ {
 		go d.load()
 		go d.process()
@@ -59,8 +224,73 @@ func (d *Dispatcher) Start(ql, cl int) error {
 	return nil
 }
 
+func (d *Dispatcher) Stop(ctx context.Context) error {
+	d.m.Lock()
+	if !d.s {
+		d.m.Unlock()
+		return fmt.Errorf("the dispatcher isn't started")
+	}
+	if d.q {
+		d.m.Unlock()
+		return ErrStopped
+	}
+	d.q = true
+	d.m.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		d.j.Wait()
+		close(d.ch.l)
+		close(d.ch.p)
+		close(d.ch.s)
+		close(d.ch.r)
+		d.w.Wait()
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		d.a.cancel()
+		return ctx.Err()
+	}
+}
+
+func (d *Dispatcher) get(k string) ([]byte, bool) {
+	if d.c == nil {
+		return nil, false
+	}
+
+	b, _, ok := d.c.Get(k)
+
+	return b, ok
+}
+
+func (d *Dispatcher) set(k string, b []byte, ttl time.Duration) {
+	if d.c != nil {
+		d.c.Set(k, b, ttl)
+	}
+}
+
 func (d *Dispatcher) load() {
+	defer d.w.Done()
+
 	for r := range d.ch.l {
+		ok := false
+		if !d.stale(r.Pict.Version) {
+			r.Timing.Start("lookup")
+			r, ok = lookup(r)
+			r.Timing.Stop()
+		}
+
+		if ok {
+			d.set(r.id, r.Buff, r.Pict.CacheTTL)
+			d.ch.r <- r
+			continue
+		}
+
 		r.Timing.Start("loading")
 		r = load(r)
 		r.Timing.Stop()
@@ -74,6 +304,8 @@ func (d *Dispatcher) load() {
 }
 
 func (d *Dispatcher) process() {
+	defer d.w.Done()
+
 	for r := range d.ch.p {
 		r.Timing.Start("processing")
 		r := process(r)
@@ -88,13 +320,15 @@ func (d *Dispatcher) process() {
 }
 
 func (d *Dispatcher) save() {
+	defer d.w.Done()
+
 	for r := range d.ch.s {
 		r.Timing.Start("saving")
 		r = save(r)
 		r.Timing.Stop()
 
 		if r.IsSuccessful() {
-			d.c.set(r.Path, r)
+			d.set(r.id, r.Buff, r.Pict.CacheTTL)
 		}
 
 		d.ch.r <- r
@@ -102,21 +336,20 @@ func (d *Dispatcher) save() {
 }
 
 func (d *Dispatcher) send() {
-	for r := range d.ch.r {
-		for {
-			c := d.a.pop(r.Path)
-			if c == nil {
-				break
-			}
+	defer d.w.Done()
 
+	for r := range d.ch.r {
+		for _, c := range d.a.pop(r.id, r.a) {
 			c <- r
 			close(c)
 		}
+		d.j.Done()
 	}
 }
 
-func NewDispatcher(p picture.Pictures) *Dispatcher {
+func NewDispatcher(p picture.Pictures, c cache.Cache) *Dispatcher {
 	return &Dispatcher{
 		p: p,
+		c: c,
 	}
 }
@@ -0,0 +1,179 @@
+package dispatcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ueef/mosaic/pkg/encoder"
+	"github.com/ueef/mosaic/pkg/loader"
+	"github.com/ueef/mosaic/pkg/picture"
+	"image"
+	"testing"
+	"time"
+)
+
+type gate chan struct{}
+
+func (g gate) Load(path string) ([]byte, error) {
+	<-g
+	return nil, loader.ErrNotFound
+}
+
+type nopSaver struct{}
+
+func (nopSaver) Save(path string, data []byte) error {
+	return nil
+}
+
+type nopEncoder struct{}
+
+func (nopEncoder) Encode(img image.Image) ([]byte, error) {
+	return nil, nil
+}
+
+func (nopEncoder) GetMime() string {
+	return "image/png"
+}
+
+func newPicture(l loader.Loader, version string, timeout time.Duration) *picture.Picture {
+	p := picture.New(nopSaver{}, l, nil, []encoder.Encoder{nopEncoder{}}, nil, nil)
+	p.Version = version
+	p.Timeout = timeout
+
+	return p
+}
+
+// fill starts a dispatcher with one worker per stage and fills its queue with
+// n requests of distinct paths, the first of them held by the loader.
+func fill(t *testing.T, g gate, n int) (*Dispatcher, chan error) {
+	d := NewDispatcher(picture.Pictures{newPicture(g, "a", 0)}, nil)
+	if err := d.Start(1); err != nil {
+		t.Fatal(err)
+	}
+
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			c, err := d.Dispatch("", fmt.Sprintf("/%d", i))
+			if err == nil {
+				err = (<-c).Err
+			}
+			errs <- err
+		}(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	return d, errs
+}
+
+func within(t *testing.T, d time.Duration, f func()) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(d):
+		t.Fatalf("not done within %s", d)
+	}
+}
+
+func TestReloadUnderLoad(t *testing.T) {
+	g := make(gate)
+	d, errs := fill(t, g, 6)
+
+	within(t, time.Second, func() {
+		d.Reload(picture.Pictures{newPicture(g, "b", 0)})
+	})
+	close(g)
+
+	within(t, time.Second, func() {
+		for i := 0; i < 6; i++ {
+			<-errs
+		}
+		c, err := d.Dispatch("", "/new")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		<-c
+	})
+
+	if !d.stale("b") || d.stale("a") {
+		t.Errorf("only the version of a new picture must be stale")
+	}
+}
+
+func TestStopUnderLoad(t *testing.T) {
+	g := make(gate)
+	d, errs := fill(t, g, 6)
+	defer close(g)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	var err error
+	within(t, time.Second, func() {
+		err = d.Stop(ctx)
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	within(t, time.Second, func() {
+		for i := 0; i < 6; i++ {
+			<-errs
+		}
+	})
+
+	_, err = d.Dispatch("", "/new")
+	if !errors.Is(err, ErrStopped) {
+		t.Errorf("got %v, want %v", err, ErrStopped)
+	}
+}
+
+func TestDispatchDeadline(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		ctx     time.Duration
+		timeout time.Duration
+	}{
+		{"context", 100 * time.Millisecond, 0},
+		{"timeout", 0, 100 * time.Millisecond},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			g := make(gate)
+			defer close(g)
+
+			d := NewDispatcher(picture.Pictures{newPicture(g, "a", tc.timeout)}, nil)
+			if err := d.Start(1); err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 2; i++ {
+				go d.Dispatch("", fmt.Sprintf("/%d", i))
+			}
+			time.Sleep(50 * time.Millisecond)
+
+			ctx := context.Background()
+			if tc.ctx > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tc.ctx)
+				defer cancel()
+			}
+
+			var err error
+			within(t, time.Second, func() {
+				var c <-chan *Response
+				c, err = d.DispatchContext(ctx, "", "/late")
+				if err == nil {
+					err = (<-c).Err
+				}
+			})
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("got %v, want %v", err, context.DeadlineExceeded)
+			}
+		})
+	}
+}
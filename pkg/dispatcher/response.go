@@ -1,25 +1,51 @@
 package dispatcher
 
 import (
+	"context"
+	"github.com/ueef/mosaic/pkg/encoder"
+	"github.com/ueef/mosaic/pkg/filter"
+	"github.com/ueef/mosaic/pkg/metadata"
 	"github.com/ueef/mosaic/pkg/picture"
 )
 
+type Request struct {
+	Host   string
+	Path   string
+	Accept string
+	Query  string
+}
+
 type Response struct {
 	Err    error
 	Buff   []byte
+	Key    string
 	Path   string
 	Pict   *picture.Picture
+	Enc    encoder.Encoder
+	Meta   metadata.Metadata
+	Params filter.Params
 	Timing Timer
+	id     string
+	a      *awaiter
 }
 
 func (r Response) IsSuccessful() bool {
 	return r.Err == nil
 }
 
+func (r Response) context() context.Context {
+	if r.a == nil {
+		return context.Background()
+	}
+
+	return r.a.ctx
+}
+
 func NewResponse(path string, pict *picture.Picture) *Response {
 	return &Response{
 		Err:    nil,
 		Buff:   nil,
+		Key:    path,
 		Path:   path,
 		Pict:   pict,
 		Timing: NewTimer(),
@@ -30,8 +56,21 @@ func NewErrorResponse(path string, err error, timing Timer) *Response {
 	return &Response{
 		Err:    err,
 		Buff:   nil,
+		Key:    path,
 		Path:   path,
 		Pict:   nil,
 		Timing: timing,
 	}
 }
+
+type LoadError struct {
+	Err error
+}
+
+func (e *LoadError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *LoadError) Unwrap() error {
+	return e.Err
+}
@@ -2,27 +2,110 @@ package dispatcher
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/rwcarlsen/goexif/exif"
+	"github.com/ueef/mosaic/pkg/encoder"
+	"github.com/ueef/mosaic/pkg/filter"
+	"github.com/ueef/mosaic/pkg/icc"
+	"github.com/ueef/mosaic/pkg/loader"
+	"github.com/ueef/mosaic/pkg/metadata"
+	"github.com/ueef/mosaic/pkg/picture"
+	"github.com/ueef/mosaic/pkg/saver"
 	"github.com/ueef/mosaic/pkg/utils"
+	_ "golang.org/x/image/webp"
 	"image"
 	_ "image/gif"
 	_ "image/jpeg"
 	_ "image/png"
+	"strconv"
 )
 
+func lookup(r *Response) (*Response, bool) {
+	l, ok := r.Pict.Saver.(saver.Lookuper)
+	if !ok {
+		return r, false
+	}
+
+	b, ok, err := l.Lookup(r.Key)
+	if err != nil {
+		fmt.Println(err)
+		return r, false
+	}
+	if !ok {
+		return r, false
+	}
+	r.Buff = b
+
+	return r, true
+}
+
 func load(r *Response) *Response {
-	b, err := r.Pict.Loader.Load(r.Path)
+	ctx := r.context()
+	err := ctx.Err()
+	if err != nil {
+		return fail(r, err)
+	}
+
+	b, err := fetch(ctx, r.Pict.Loader, r.Path)
 	if err != nil {
-		return NewErrorResponse(r.Path, err, r.Timing)
+		return fail(r, &LoadError{err})
 	}
 	r.Buff = b
 
+	if r.Pict.Sidecar != "" {
+		p, err := loadSidecar(ctx, r.Pict.Loader, r.Path+r.Pict.Sidecar)
+		if err != nil {
+			if !errors.Is(err, loader.ErrNotFound) {
+				fmt.Println(err)
+			}
+		} else {
+			r.Params = p.Merge(r.Params)
+		}
+	}
+
 	return r
 }
 
+func fetch(ctx context.Context, l loader.Loader, path string) ([]byte, error) {
+	if cl, ok := l.(loader.ContextLoader); ok {
+		return cl.LoadContext(ctx, path)
+	}
+
+	return l.Load(path)
+}
+
+func loadSidecar(ctx context.Context, l loader.Loader, path string) (filter.Params, error) {
+	b, err := fetch(ctx, l, path)
+	if err != nil {
+		return nil, err
+	}
+
+	m := map[string]interface{}{}
+	err = json.Unmarshal(b, &m)
+	if err != nil {
+		return nil, fmt.Errorf("a sidecar \"%s\" is invalid: %s", path, err)
+	}
+
+	p := filter.Params{}
+	for k, v := range m {
+		switch v := v.(type) {
+		case string:
+			p[k] = v
+		case float64:
+			p[k] = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			p[k] = strconv.FormatBool(v)
+		}
+	}
+
+	return p, nil
+}
+
 func save(r *Response) *Response {
-	err := r.Pict.Saver.Save(r.Path, r.Buff)
+	err := r.Pict.Saver.Save(r.Key, r.Buff)
 	if err != nil {
 		fmt.Println(err)
 	}
@@ -31,37 +114,160 @@ func save(r *Response) *Response {
 }
 
 func process(r *Response) *Response {
+	ctx := r.context()
+	err := ctx.Err()
+	if err != nil {
+		return fail(r, err)
+	}
+
+	// frames are composed only for an encoder to keep them
+	ae, ok := r.Enc.(encoder.AnimationEncoder)
+	frame := r.Pict.Frame
+	if frame == picture.AllFrames && !ok {
+		frame = 0
+	}
+
 	r.Timing.Start("processing.decoding")
-	img, _, err := image.Decode(bytes.NewReader(r.Buff))
+	img, a, err := decode(r.Buff, frame)
 	r.Timing.Stop()
 	if err != nil {
-		return NewErrorResponse(r.Path, err, r.Timing)
+		return fail(r, err)
 	}
 
-	r.Timing.Start("processing.orientation")
-	img = fixOrientation(img, r.Buff)
-	r.Timing.Stop()
-	r.Buff = nil
+	if a != nil {
+		r.Buff = nil
 
-	for i := range r.Pict.Filters {
-		r.Timing.Start("processing." + fmt.Sprintf("%T", r.Pict.Filters[i])[1:])
-		img, err = r.Pict.Filters[i].Apply(img)
+		r.Timing.Start("processing.frames")
+		for i := range a.Frames {
+			a.Frames[i], err = applyFilters(ctx, a.Frames[i], r.Pict.Filters, r.Params, nil)
+			if err != nil {
+				break
+			}
+		}
 		r.Timing.Stop()
 		if err != nil {
-			return NewErrorResponse(r.Path, err, r.Timing)
+			return fail(r, err)
+		}
+
+		r.Timing.Start("processing.encoding")
+		r.Buff, err = ae.EncodeAnimation(a)
+		r.Timing.Stop()
+		if err != nil {
+			return fail(r, err)
+		}
+
+		return r
+	}
+
+	m := metadata.Extract(r.Buff)
+	r.Meta = m.Select(r.Pict.Metadata)
+	if c, ok := img.(*image.CMYK); ok {
+		r.Timing.Start("processing.cmyk")
+		img = convertCMYK(c, m.Icc)
+		r.Timing.Stop()
+		r.Meta.Icc = nil
+	} else if len(m.Icc) > 0 {
+		r.Meta.Icc = m.Icc
+		if r.Pict.Icc != icc.ModeKeep {
+			r.Timing.Start("processing.icc")
+			i, ok := convertProfile(img, m.Icc)
+			r.Timing.Stop()
+			if ok {
+				img = i
+				r.Meta.Icc = nil
+			}
 		}
 	}
 
+	if r.Pict.AutoOrient {
+		r.Timing.Start("processing.orientation")
+		img = fixOrientation(img, r.Buff)
+		r.Meta = r.Meta.ResetOrientation()
+		r.Timing.Stop()
+	}
+	r.Buff = nil
+
+	img, err = applyFilters(ctx, img, r.Pict.Filters, r.Params, r.Timing)
+	if err != nil {
+		return fail(r, err)
+	}
+
 	r.Timing.Start("processing.encoding")
-	r.Buff, err = r.Pict.Encoder.Encode(img)
+	if me, ok := r.Enc.(encoder.MetadataEncoder); ok {
+		r.Buff, err = me.EncodeMetadata(img, r.Meta)
+	} else {
+		r.Buff, err = r.Enc.Encode(img)
+	}
 	r.Timing.Stop()
 	if err != nil {
-		return NewErrorResponse(r.Path, err, r.Timing)
+		return fail(r, err)
 	}
 
 	return r
 }
 
+func applyFilters(ctx context.Context, img image.Image, f []filter.Filter, p filter.Params, t Timer) (image.Image, error) {
+	var err error
+	for i := range f {
+		err = ctx.Err()
+		if err != nil {
+			return nil, err
+		}
+
+		if t != nil {
+			t.Start("processing." + fmt.Sprintf("%T", f[i])[1:])
+		}
+		if pf, ok := f[i].(filter.ParamFilter); ok && len(p) > 0 {
+			img, err = pf.ApplyParams(img, p)
+		} else {
+			img, err = f[i].Apply(img)
+		}
+		if t != nil {
+			t.Stop()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return img, nil
+}
+
+func convertProfile(i image.Image, b []byte) (image.Image, bool) {
+	p, err := icc.Parse(b)
+	if err != nil {
+		return i, false
+	}
+
+	c, err := p.ToSRGB(i)
+	if err != nil {
+		return i, false
+	}
+
+	return c, true
+}
+
+func convertCMYK(i *image.CMYK, b []byte) image.Image {
+	p, err := icc.Parse(b)
+	if err == nil && p.IsCMYK() {
+		if c, err := p.ToSRGB(i); err == nil {
+			return c
+		}
+	}
+
+	return icc.CMYKToRGBA(i)
+}
+
+func fail(r *Response, err error) *Response {
+	e := NewErrorResponse(r.Path, err, r.Timing)
+	e.Key = r.Key
+	e.Enc = r.Enc
+	e.id = r.id
+	e.a = r.a
+
+	return e
+}
+
 func fixOrientation(i image.Image, b []byte) image.Image {
 	e, err := exif.Decode(bytes.NewReader(b))
 	if err != nil {
@@ -79,10 +285,18 @@ func fixOrientation(i image.Image, b []byte) image.Image {
 	}
 
 	switch o {
+	case 2:
+		return utils.FlipHorizontal(i)
 	case 3:
 		return utils.Rotate180(i)
+	case 4:
+		return utils.FlipVertical(i)
+	case 5:
+		return utils.Transpose(i)
 	case 6:
 		return utils.Rotate90(i)
+	case 7:
+		return utils.Transverse(i)
 	case 8:
 		return utils.Rotate270(i)
 	}
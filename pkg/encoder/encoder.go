@@ -2,24 +2,48 @@ package encoder
 
 import (
 	"errors"
+	"github.com/ueef/mosaic/pkg/metadata"
 	"github.com/ueef/mosaic/pkg/parse"
 	"image"
 )
 
 const TypePng = "png"
 const TypeJpeg = "jpeg"
+const TypeWebp = "webp"
+const TypeGif = "gif"
 
 type Encoder interface {
 	Encode(img image.Image) ([]byte, error)
 	GetMime() string
 }
 
+type Animation struct {
+	Frames    []image.Image
+	Delays    []int
+	Disposals []byte
+	LoopCount int
+}
+
+type AnimationEncoder interface {
+	Encoder
+	EncodeAnimation(a *Animation) ([]byte, error)
+}
+
+type MetadataEncoder interface {
+	Encoder
+	EncodeMetadata(img image.Image, m metadata.Metadata) ([]byte, error)
+}
+
 func New(t string, m map[string]interface{}) (Encoder, error) {
 	switch t {
 	case TypePng:
 		return NewPngEncoderFromMap(m)
 	case TypeJpeg:
 		return NewJpegEncoderFromMap(m)
+	case TypeWebp:
+		return NewWebpEncoderFromMap(m)
+	case TypeGif:
+		return NewGifEncoderFromMap(m)
 	}
 
 	return nil, errors.New("type of encoder \"" + t + "\" is undefined")
@@ -41,7 +65,12 @@ func NewFromConfig(c interface{}) (s Encoder, err error) {
 		return nil, err
 	}
 
-	return New(t, m)
+	v, err := New(t, m)
+	if err != nil {
+		return nil, parse.Nest(err, "config")
+	}
+
+	return v, nil
 }
 
 type buffer []byte
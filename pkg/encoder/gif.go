@@ -0,0 +1,135 @@
+package encoder
+
+import (
+	"fmt"
+	"github.com/ueef/mosaic/pkg/parse"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+)
+
+const QuantizerMedianCut = "median_cut"
+const QuantizerPlan9 = "plan9"
+const QuantizerCustom = "custom"
+
+type GifEncoder struct {
+	Colors    int
+	Quantizer draw.Quantizer
+	Dithering bool
+}
+
+func (e GifEncoder) Encode(img image.Image) ([]byte, error) {
+	b := buffer{}
+
+	err := gif.Encode(&b, img, &gif.Options{
+		NumColors: e.Colors,
+		Quantizer: e.Quantizer,
+		Drawer:    e.drawer(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+func (e GifEncoder) EncodeAnimation(a *Animation) ([]byte, error) {
+	g := &gif.GIF{
+		Image:     make([]*image.Paletted, len(a.Frames)),
+		Delay:     a.Delays,
+		Disposal:  a.Disposals,
+		LoopCount: a.LoopCount,
+	}
+
+	d := e.drawer()
+	for i, f := range a.Frames {
+		p := palette.Plan9[:e.Colors]
+		if e.Quantizer != nil {
+			p = e.Quantizer.Quantize(make(color.Palette, 0, e.Colors), f)
+		}
+
+		b := f.Bounds()
+		g.Image[i] = image.NewPaletted(b, p)
+		d.Draw(g.Image[i], b, f, b.Min)
+	}
+
+	b := buffer{}
+	err := gif.EncodeAll(&b, g)
+	if err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+func (e GifEncoder) drawer() draw.Drawer {
+	if e.Dithering {
+		return draw.FloydSteinberg
+	}
+
+	return draw.Src
+}
+
+func (e GifEncoder) GetMime() string {
+	return "image/gif"
+}
+
+func NewGifEncoder(colors int, quantizer draw.Quantizer, dithering bool) *GifEncoder {
+	return &GifEncoder{
+		Colors:    colors,
+		Quantizer: quantizer,
+		Dithering: dithering,
+	}
+}
+
+func NewGifEncoderFromMap(m map[string]interface{}) (*GifEncoder, error) {
+	c, ok, err := parse.GetIntFromMap("colors", m)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		c = 256
+	}
+	if c < 2 || c > 256 {
+		return nil, fmt.Errorf("a value of a key \"colors\" must be between 2 and 256, got %d", c)
+	}
+
+	t, ok, err := parse.GetStringFromMap("quantizer", m)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		t = QuantizerMedianCut
+	}
+
+	var q draw.Quantizer
+	switch t {
+	case QuantizerMedianCut:
+		q = NewMedianCutQuantizer()
+	case QuantizerPlan9:
+		q = NewPaletteQuantizer(palette.Plan9)
+	case QuantizerCustom:
+		p, err := parse.GetRequiredColorsFromMap("palette", m)
+		if err != nil {
+			return nil, err
+		}
+		if len(p) == 0 || len(p) > 256 {
+			return nil, fmt.Errorf("a value of a key \"palette\" must contain from 1 to 256 colors, got %d", len(p))
+		}
+		q = NewPaletteQuantizer(color.Palette(p))
+	default:
+		return nil, fmt.Errorf("a quantizer \"%s\" is undefined. expected %s, %s or %s", t, QuantizerMedianCut, QuantizerPlan9, QuantizerCustom)
+	}
+
+	d, ok, err := parse.GetBoolFromMap("dithering", m)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		d = true
+	}
+
+	return NewGifEncoder(c, q, d), nil
+}
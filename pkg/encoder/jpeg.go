@@ -1,6 +1,7 @@
 package encoder
 
 import (
+	"github.com/ueef/mosaic/pkg/metadata"
 	"github.com/ueef/mosaic/pkg/parse"
 	"image"
 	"image/jpeg"
@@ -23,6 +24,15 @@ func (e JpegEncoder) Encode(img image.Image) ([]byte, error) {
 	return b, nil
 }
 
+func (e JpegEncoder) EncodeMetadata(img image.Image, m metadata.Metadata) ([]byte, error) {
+	b, err := e.Encode(img)
+	if err != nil || m.IsEmpty() {
+		return b, err
+	}
+
+	return metadata.EmbedJpeg(b, m)
+}
+
 func (e JpegEncoder) GetMime() string {
 	return "image/jpeg"
 }
@@ -1,6 +1,7 @@
 package encoder
 
 import (
+	"github.com/ueef/mosaic/pkg/metadata"
 	"image"
 	"image/png"
 )
@@ -18,6 +19,15 @@ func (e PngEncoder) Encode(img image.Image) ([]byte, error) {
 	return b, nil
 }
 
+func (e PngEncoder) EncodeMetadata(img image.Image, m metadata.Metadata) ([]byte, error) {
+	b, err := e.Encode(img)
+	if err != nil || m.IsEmpty() {
+		return b, err
+	}
+
+	return metadata.EmbedPng(b, m)
+}
+
 func (e PngEncoder) GetMime() string {
 	return "image/png"
 }
@@ -0,0 +1,158 @@
+package encoder
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"sort"
+)
+
+const quantizerSamples = 1 << 16
+
+type medianCut struct{}
+
+func (q medianCut) Quantize(p color.Palette, m image.Image) color.Palette {
+	n := cap(p) - len(p)
+	if n <= 0 {
+		return p
+	}
+
+	px, t := sample(m)
+	if t {
+		p = append(p, color.RGBA{})
+		n--
+	}
+	if len(px) == 0 {
+		return p
+	}
+
+	b := [][][3]uint8{px}
+	for len(b) < n {
+		bi, bc, bs := -1, 0, 0
+		for i := range b {
+			c, r := boxAxis(b[i])
+			if s := r * len(b[i]); r > 0 && s > bs {
+				bi, bc, bs = i, c, s
+			}
+		}
+		if bi < 0 {
+			break
+		}
+
+		v := b[bi]
+		sort.Slice(v, func(i, j int) bool {
+			return v[i][bc] < v[j][bc]
+		})
+
+		h := len(v) / 2
+		b[bi] = v[:h]
+		b = append(b, v[h:])
+	}
+
+	for i := range b {
+		var s [3]int
+		for _, c := range b[i] {
+			s[0] += int(c[0])
+			s[1] += int(c[1])
+			s[2] += int(c[2])
+		}
+
+		l := len(b[i])
+		p = append(p, color.RGBA{
+			R: uint8(s[0] / l),
+			G: uint8(s[1] / l),
+			B: uint8(s[2] / l),
+			A: 255,
+		})
+	}
+
+	return p
+}
+
+type fixed struct {
+	p color.Palette
+}
+
+func (q fixed) Quantize(p color.Palette, m image.Image) color.Palette {
+	n := cap(p) - len(p)
+	if len(q.p) <= n {
+		return append(p, q.p...)
+	}
+
+	px, _ := sample(m)
+	u := make([]int, len(q.p))
+	for _, c := range px {
+		u[q.p.Index(color.RGBA{R: c[0], G: c[1], B: c[2], A: 255})]++
+	}
+
+	i := make([]int, len(q.p))
+	for j := range i {
+		i[j] = j
+	}
+	sort.SliceStable(i, func(a, b int) bool {
+		return u[i[a]] > u[i[b]]
+	})
+
+	i = i[:n]
+	sort.Ints(i)
+	for _, j := range i {
+		p = append(p, q.p[j])
+	}
+
+	return p
+}
+
+func NewMedianCutQuantizer() draw.Quantizer {
+	return &medianCut{}
+}
+
+func NewPaletteQuantizer(p color.Palette) draw.Quantizer {
+	return &fixed{p}
+}
+
+func boxAxis(b [][3]uint8) (int, int) {
+	min := [3]uint8{255, 255, 255}
+	max := [3]uint8{}
+	for _, c := range b {
+		for i := range c {
+			if c[i] < min[i] {
+				min[i] = c[i]
+			}
+			if c[i] > max[i] {
+				max[i] = c[i]
+			}
+		}
+	}
+
+	a, r := 0, 0
+	for i := range min {
+		if d := int(max[i]) - int(min[i]); d > r {
+			a, r = i, d
+		}
+	}
+
+	return a, r
+}
+
+func sample(m image.Image) ([][3]uint8, bool) {
+	b := m.Bounds()
+	s := 1
+	for b.Dx()*b.Dy()/(s*s) > quantizerSamples {
+		s++
+	}
+
+	t := false
+	px := make([][3]uint8, 0, (b.Dx()/s+1)*(b.Dy()/s+1))
+	for y := b.Min.Y; y < b.Max.Y; y += s {
+		for x := b.Min.X; x < b.Max.X; x += s {
+			c := color.NRGBAModel.Convert(m.At(x, y)).(color.NRGBA)
+			if c.A < 128 {
+				t = true
+				continue
+			}
+			px = append(px, [3]uint8{c.R, c.G, c.B})
+		}
+	}
+
+	return px, t
+}
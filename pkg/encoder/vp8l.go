@@ -0,0 +1,628 @@
+package encoder
+
+import (
+	"encoding/binary"
+	"image"
+	"image/draw"
+	"sort"
+)
+
+const vp8lPredictorBits = 4
+const vp8lMinMatch = 3
+const vp8lMaxMatch = 4096
+const vp8lMaxDistance = 1<<20 - 120
+const vp8lHashBits = 16
+const vp8lChainLength = 32
+
+var vp8lCodeLengthOrder = [19]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+type bitWriter struct {
+	b []byte
+	v uint64
+	n uint
+}
+
+func (w *bitWriter) write(v uint32, n uint) {
+	w.v |= uint64(v) << w.n
+	w.n += n
+	for w.n >= 8 {
+		w.b = append(w.b, byte(w.v))
+		w.v >>= 8
+		w.n -= 8
+	}
+}
+
+func (w *bitWriter) flush() []byte {
+	if w.n > 0 {
+		w.b = append(w.b, byte(w.v))
+		w.v, w.n = 0, 0
+	}
+
+	return w.b
+}
+
+type huffman struct {
+	l []uint8
+	c []uint16
+	n int
+}
+
+func (h *huffman) write(w *bitWriter, s int) {
+	if h.n > 1 {
+		w.write(uint32(h.c[s]), uint(h.l[s]))
+	}
+}
+
+func newHuffman(f []int, limit int) *huffman {
+	h := &huffman{
+		l: make([]uint8, len(f)),
+		c: make([]uint16, len(f)),
+	}
+
+	s := make([]int, 0, len(f))
+	for i := range f {
+		if f[i] > 0 {
+			s = append(s, i)
+		}
+	}
+	h.n = len(s)
+
+	switch h.n {
+	case 0:
+		return h
+	case 1:
+		h.l[s[0]] = 1
+		return h
+	}
+
+	w := make([]int, len(f))
+	copy(w, f)
+	for !huffmanLengths(w, s, h.l, limit) {
+		for _, i := range s {
+			w[i] = (w[i] + 1) / 2
+		}
+	}
+
+	var next [16]uint16
+	var count [16]uint16
+	for _, i := range s {
+		count[h.l[i]]++
+	}
+	for l, c := 1, uint16(0); l < len(next); l++ {
+		c = (c + count[l-1]) << 1
+		next[l] = c
+	}
+	for _, i := range s {
+		l := h.l[i]
+		c := next[l]
+		next[l]++
+
+		r := uint16(0)
+		for j := uint8(0); j < l; j++ {
+			r = r<<1 | c&1
+			c >>= 1
+		}
+		h.c[i] = r
+	}
+
+	return h
+}
+
+func huffmanLengths(f []int, s []int, l []uint8, limit int) bool {
+	type node struct {
+		f int
+		l int
+		r int
+	}
+
+	n := make([]node, 0, len(s)*2)
+	for _, i := range s {
+		n = append(n, node{f[i], -1, i})
+	}
+	sort.SliceStable(n, func(i, j int) bool {
+		return n[i].f < n[j].f
+	})
+
+	leaves, inner := 0, len(n)
+	pick := func() int {
+		if leaves < len(s) && (inner >= len(n) || n[leaves].f <= n[inner].f) {
+			leaves++
+			return leaves - 1
+		}
+		inner++
+		return inner - 1
+	}
+
+	for len(n) < len(s)*2-1 {
+		a, b := pick(), pick()
+		n = append(n, node{n[a].f + n[b].f, a, b})
+	}
+
+	d := make([]int, len(n))
+	for i := len(n) - 1; i >= len(s); i-- {
+		d[n[i].l] = d[i] + 1
+		d[n[i].r] = d[i] + 1
+	}
+
+	for i := 0; i < len(s); i++ {
+		if d[i] > limit {
+			return false
+		}
+	}
+	for i := 0; i < len(s); i++ {
+		l[n[i].r] = uint8(d[i])
+	}
+
+	return true
+}
+
+type vp8lSymbol struct {
+	p uint32
+	l int
+	d int
+}
+
+func vp8lPrefix(v int) (int, uint, uint32) {
+	if v <= 4 {
+		return v - 1, 0, 0
+	}
+
+	d := v - 1
+	hb := uint(0)
+	for d>>(hb+1) > 0 {
+		hb++
+	}
+
+	e := hb - 1
+	return int(2*hb) + (d>>e)&1, e, uint32(d & (1<<e - 1))
+}
+
+func encodeVP8L(img image.Image) []byte {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	rgba, ok := img.(*image.NRGBA)
+	if !ok || rgba.Rect.Min != (image.Point{}) {
+		rgba = image.NewNRGBA(image.Rect(0, 0, w, h))
+		draw.Draw(rgba, rgba.Bounds(), img, b.Min, draw.Src)
+	}
+
+	alpha := false
+	p := make([]uint32, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := y*rgba.Stride + x*4
+			r, g, b, a := uint32(rgba.Pix[i]), uint32(rgba.Pix[i+1]), uint32(rgba.Pix[i+2]), uint32(rgba.Pix[i+3])
+			if a != 0xff {
+				alpha = true
+			}
+			p[y*w+x] = a<<24 | ((r-g)&0xff)<<16 | g<<8 | (b-g)&0xff
+		}
+	}
+
+	bw := &bitWriter{}
+	bw.write(0x2f, 8)
+	bw.write(uint32(w-1), 14)
+	bw.write(uint32(h-1), 14)
+	if alpha {
+		bw.write(1, 1)
+	} else {
+		bw.write(0, 1)
+	}
+	bw.write(0, 3)
+
+	bw.write(1, 1)
+	bw.write(2, 2)
+
+	bw.write(1, 1)
+	bw.write(0, 2)
+	bw.write(vp8lPredictorBits-2, 3)
+	m, tw, th := vp8lPredict(p, w, h, vp8lPredictorBits)
+	vp8lWritePix(bw, m, tw, th, false)
+
+	bw.write(0, 1)
+	vp8lWritePix(bw, p, w, h, true)
+
+	d := bw.flush()
+	n := len(d)
+	if n%2 == 1 {
+		d = append(d, 0)
+	}
+
+	o := make([]byte, 0, 20+len(d))
+	o = append(o, 'R', 'I', 'F', 'F', 0, 0, 0, 0, 'W', 'E', 'B', 'P', 'V', 'P', '8', 'L', 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(o[4:], uint32(12+len(d)))
+	binary.LittleEndian.PutUint32(o[16:], uint32(n))
+
+	return append(o, d...)
+}
+
+func vp8lPredict(p []uint32, w, h int, bits uint) ([]uint32, int, int) {
+	tw, th := (w+1<<bits-1)>>bits, (h+1<<bits-1)>>bits
+	m := make([]uint32, tw*th)
+	r := make([]uint32, len(p))
+
+	for ty := 0; ty < th; ty++ {
+		for tx := 0; tx < tw; tx++ {
+			x0, y0 := tx<<bits, ty<<bits
+			x1, y1 := x0+1<<bits, y0+1<<bits
+			if x1 > w {
+				x1 = w
+			}
+			if y1 > h {
+				y1 = h
+			}
+
+			best, cost := 0, -1
+			for mode := 0; mode < 14; mode++ {
+				c := 0
+				for y := y0; y < y1 && (cost < 0 || c < cost); y++ {
+					for x := x0; x < x1; x++ {
+						c += vp8lCost(vp8lSub(p[y*w+x], vp8lPredictor(p, w, x, y, mode)))
+					}
+				}
+				if cost < 0 || c < cost {
+					best, cost = mode, c
+				}
+			}
+
+			m[ty*tw+tx] = 0xff000000 | uint32(best)<<8
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					r[y*w+x] = vp8lSub(p[y*w+x], vp8lPredictor(p, w, x, y, best))
+				}
+			}
+		}
+	}
+
+	copy(p, r)
+
+	return m, tw, th
+}
+
+func vp8lPredictor(p []uint32, w, x, y, mode int) uint32 {
+	i := y*w + x
+	switch {
+	case x == 0 && y == 0:
+		return 0xff000000
+	case y == 0:
+		return p[i-1]
+	case x == 0:
+		return p[i-w]
+	}
+
+	l, t, tl, tr := p[i-1], p[i-w], p[i-w-1], p[i-w+1]
+	switch mode {
+	case 0:
+		return 0xff000000
+	case 1:
+		return l
+	case 2:
+		return t
+	case 3:
+		return tr
+	case 4:
+		return tl
+	case 5:
+		return vp8lAvg(vp8lAvg(l, tr), t)
+	case 6:
+		return vp8lAvg(l, tl)
+	case 7:
+		return vp8lAvg(l, t)
+	case 8:
+		return vp8lAvg(tl, t)
+	case 9:
+		return vp8lAvg(t, tr)
+	case 10:
+		return vp8lAvg(vp8lAvg(l, tl), vp8lAvg(t, tr))
+	case 11:
+		if vp8lDist(tl, t) < vp8lDist(tl, l) {
+			return l
+		}
+		return t
+	case 12:
+		return vp8lMap(l, t, tl, func(a, b, c int) int {
+			return vp8lClamp(a + b - c)
+		})
+	}
+
+	return vp8lMap(vp8lAvg(l, t), tl, 0, func(a, b, _ int) int {
+		return vp8lClamp(a + (a-b)/2)
+	})
+}
+
+func vp8lMap(a, b, c uint32, f func(a, b, c int) int) uint32 {
+	v := uint32(0)
+	for s := uint(0); s < 32; s += 8 {
+		v |= uint32(f(int(a>>s&0xff), int(b>>s&0xff), int(c>>s&0xff))) << s
+	}
+
+	return v
+}
+
+func vp8lAvg(a, b uint32) uint32 {
+	return (((a ^ b) & 0xfefefefe) >> 1) + (a & b)
+}
+
+func vp8lDist(a, b uint32) int {
+	d := 0
+	for s := uint(0); s < 32; s += 8 {
+		v := int(a>>s&0xff) - int(b>>s&0xff)
+		if v < 0 {
+			v = -v
+		}
+		d += v
+	}
+
+	return d
+}
+
+func vp8lClamp(v int) int {
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+
+	return v
+}
+
+func vp8lSub(a, b uint32) uint32 {
+	return vp8lMap(a, b, 0, func(a, b, _ int) int {
+		return (a - b) & 0xff
+	})
+}
+
+func vp8lCost(v uint32) int {
+	c := 0
+	for s := uint(0); s < 32; s += 8 {
+		b := int(int8(v >> s))
+		if b < 0 {
+			b = -b
+		}
+		c += b
+	}
+
+	return c
+}
+
+func vp8lBackwardRefs(p []uint32, w int, lz bool) []vp8lSymbol {
+	s := make([]vp8lSymbol, 0, len(p))
+	if !lz {
+		for i := range p {
+			s = append(s, vp8lSymbol{p: p[i]})
+		}
+		return s
+	}
+
+	head := make([]int32, 1<<vp8lHashBits)
+	for i := range head {
+		head[i] = -1
+	}
+	prev := make([]int32, len(p))
+	hash := func(i int) uint32 {
+		return ((p[i] * 0x1e35a7bd) ^ (p[i+1] * 0x9e3779b1)) >> (32 - vp8lHashBits) & (1<<vp8lHashBits - 1)
+	}
+	insert := func(i int) {
+		if i+1 < len(p) {
+			k := hash(i)
+			prev[i] = head[k]
+			head[k] = int32(i)
+		}
+	}
+	match := func(i, j int) int {
+		l := 0
+		for i+l < len(p) && l < vp8lMaxMatch && p[i+l] == p[j+l] {
+			l++
+		}
+		return l
+	}
+
+	for i := 0; i < len(p); {
+		bl, bd := 0, 0
+		for _, d := range [2]int{1, w} {
+			if d <= i {
+				if l := match(i, i-d); l > bl {
+					bl, bd = l, d
+				}
+			}
+		}
+		if i+1 < len(p) {
+			j := head[hash(i)]
+			for c := 0; j >= 0 && c < vp8lChainLength && i-int(j) <= vp8lMaxDistance; c++ {
+				if l := match(i, int(j)); l > bl {
+					bl, bd = l, i-int(j)
+				}
+				j = prev[j]
+			}
+		}
+
+		if bl < vp8lMinMatch {
+			s = append(s, vp8lSymbol{p: p[i]})
+			insert(i)
+			i++
+			continue
+		}
+
+		s = append(s, vp8lSymbol{l: bl, d: bd})
+		for k := 0; k < bl; k++ {
+			insert(i + k)
+		}
+		i += bl
+	}
+
+	return s
+}
+
+func vp8lDistanceCode(d, w int) int {
+	switch d {
+	case w:
+		return 1
+	case 1:
+		return 2
+	}
+
+	return d + 120
+}
+
+func vp8lWritePix(bw *bitWriter, p []uint32, w, h int, top bool) {
+	bw.write(0, 1)
+	if top {
+		bw.write(0, 1)
+	}
+
+	s := vp8lBackwardRefs(p, w, top)
+
+	var f [5][]int
+	f[0] = make([]int, 256+24)
+	f[1] = make([]int, 256)
+	f[2] = make([]int, 256)
+	f[3] = make([]int, 256)
+	f[4] = make([]int, 40)
+	for _, v := range s {
+		if v.l == 0 {
+			f[0][v.p>>8&0xff]++
+			f[1][v.p>>16&0xff]++
+			f[2][v.p&0xff]++
+			f[3][v.p>>24]++
+			continue
+		}
+
+		c, _, _ := vp8lPrefix(v.l)
+		f[0][256+c]++
+		c, _, _ = vp8lPrefix(vp8lDistanceCode(v.d, w))
+		f[4][c]++
+	}
+
+	var hc [5]*huffman
+	for i := range hc {
+		hc[i] = newHuffman(f[i], 15)
+		vp8lWriteHuffman(bw, hc[i])
+	}
+
+	for _, v := range s {
+		if v.l == 0 {
+			hc[0].write(bw, int(v.p>>8&0xff))
+			hc[1].write(bw, int(v.p>>16&0xff))
+			hc[2].write(bw, int(v.p&0xff))
+			hc[3].write(bw, int(v.p>>24))
+			continue
+		}
+
+		c, n, e := vp8lPrefix(v.l)
+		hc[0].write(bw, 256+c)
+		bw.write(e, n)
+
+		c, n, e = vp8lPrefix(vp8lDistanceCode(v.d, w))
+		hc[4].write(bw, c)
+		bw.write(e, n)
+	}
+}
+
+func vp8lWriteHuffman(bw *bitWriter, h *huffman) {
+	s := make([]int, 0, 2)
+	for i := range h.l {
+		if h.l[i] > 0 {
+			s = append(s, i)
+			if len(s) > 2 {
+				break
+			}
+		}
+	}
+
+	if len(s) == 0 {
+		s = append(s, 0)
+	}
+
+	if len(s) <= 2 && s[len(s)-1] < 256 {
+		bw.write(1, 1)
+		bw.write(uint32(len(s)-1), 1)
+		if s[0] < 2 {
+			bw.write(0, 1)
+			bw.write(uint32(s[0]), 1)
+		} else {
+			bw.write(1, 1)
+			bw.write(uint32(s[0]), 8)
+		}
+		if len(s) == 2 {
+			bw.write(uint32(s[1]), 8)
+		}
+		return
+	}
+
+	type token struct {
+		c int
+		e uint32
+	}
+
+	t := make([]token, 0, len(h.l))
+	for i := 0; i < len(h.l); {
+		v := h.l[i]
+		r := 1
+		for i+r < len(h.l) && h.l[i+r] == v {
+			r++
+		}
+		i += r
+
+		if v == 0 {
+			for r >= 11 {
+				n := r
+				if n > 138 {
+					n = 138
+				}
+				t = append(t, token{18, uint32(n - 11)})
+				r -= n
+			}
+			if r >= 3 {
+				t = append(t, token{17, uint32(r - 3)})
+				r = 0
+			}
+		} else {
+			t = append(t, token{int(v), 0})
+			r--
+			for r >= 3 {
+				n := r
+				if n > 6 {
+					n = 6
+				}
+				t = append(t, token{16, uint32(n - 3)})
+				r -= n
+			}
+		}
+
+		for ; r > 0; r-- {
+			t = append(t, token{int(v), 0})
+		}
+	}
+
+	f := make([]int, 19)
+	for _, v := range t {
+		f[v.c]++
+	}
+	ch := newHuffman(f, 7)
+
+	n := len(vp8lCodeLengthOrder)
+	for n > 4 && ch.l[vp8lCodeLengthOrder[n-1]] == 0 {
+		n--
+	}
+
+	bw.write(0, 1)
+	bw.write(uint32(n-4), 4)
+	for i := 0; i < n; i++ {
+		bw.write(uint32(ch.l[vp8lCodeLengthOrder[i]]), 3)
+	}
+	bw.write(0, 1)
+
+	for _, v := range t {
+		ch.write(bw, v.c)
+		switch v.c {
+		case 16:
+			bw.write(v.e, 2)
+		case 17:
+			bw.write(v.e, 3)
+		case 18:
+			bw.write(v.e, 7)
+		}
+	}
+}
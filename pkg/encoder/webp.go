@@ -0,0 +1,44 @@
+package encoder
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ueef/mosaic/pkg/parse"
+	"image"
+)
+
+const webpMaxSize = 1 << 14
+
+type WebpEncoder struct{}
+
+func (e WebpEncoder) Encode(img image.Image) ([]byte, error) {
+	b := img.Bounds()
+	if b.Dx() > webpMaxSize || b.Dy() > webpMaxSize {
+		return nil, fmt.Errorf("an image %dx%d is too large for webp", b.Dx(), b.Dy())
+	}
+	if b.Empty() {
+		return nil, errors.New("an empty image can't be encoded to webp")
+	}
+
+	return encodeVP8L(img), nil
+}
+
+func (e WebpEncoder) GetMime() string {
+	return "image/webp"
+}
+
+func NewWebpEncoder() *WebpEncoder {
+	return &WebpEncoder{}
+}
+
+func NewWebpEncoderFromMap(m map[string]interface{}) (*WebpEncoder, error) {
+	lossless, ok, err := parse.GetBoolFromMap("lossless", m)
+	if err != nil {
+		return nil, err
+	}
+	if ok && !lossless {
+		return nil, errors.New("lossy webp encoding isn't supported, only lossless is")
+	}
+
+	return NewWebpEncoder(), nil
+}
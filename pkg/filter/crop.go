@@ -0,0 +1,180 @@
+package filter
+
+import (
+	"fmt"
+	"github.com/anthonynsimon/bild/clone"
+	"github.com/ueef/mosaic/pkg/parse"
+	"image"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const TypeCrop = "crop"
+
+type Length struct {
+	Value    float64
+	Relative bool
+}
+
+func (l Length) IsZero() bool {
+	return l.Value == 0
+}
+
+func (l Length) Resolve(t int) int {
+	if l.Relative {
+		return int(math.Round(l.Value * float64(t) / 100))
+	}
+
+	return int(l.Value)
+}
+
+func ParseLength(s string) (Length, error) {
+	s = strings.TrimSpace(s)
+
+	l := Length{}
+	if strings.HasSuffix(s, "%") {
+		s = strings.TrimSpace(s[:len(s)-1])
+		l.Relative = true
+	}
+
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || v < 0 {
+		return Length{}, fmt.Errorf("a length \"%s\" must be a non-negative number of pixels or percents", s)
+	}
+	l.Value = v
+
+	return l, nil
+}
+
+type crop struct {
+	x Length
+	y Length
+	w Length
+	h Length
+	a bool
+	g string
+}
+
+func (f crop) Apply(img image.Image) (image.Image, error) {
+	return f.ApplyParams(img, nil)
+}
+
+func (f crop) ApplyParams(img image.Image, p Params) (image.Image, error) {
+	rgba, ok := img.(*image.RGBA)
+	if !ok {
+		rgba = clone.AsRGBA(img)
+	}
+
+	b := rgba.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if !f.w.IsZero() {
+		w = f.w.Resolve(b.Dx())
+	}
+	if !f.h.IsZero() {
+		h = f.h.Resolve(b.Dy())
+	}
+
+	var x, y int
+	if f.a {
+		x, y = f.x.Resolve(b.Dx()), f.y.Resolve(b.Dy())
+	} else {
+		var err error
+		x, y, err = place(rgba, f.g, p, w, h)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return cropRGBA(rgba, b.Min.X+x, b.Min.Y+y, b.Min.X+x+w, b.Min.Y+y+h)
+}
+
+func NewCrop(x, y, w, h Length) Filter {
+	return &crop{x: x, y: y, w: w, h: h, a: true}
+}
+
+func NewGravityCrop(w, h Length, g string) Filter {
+	if g == "" {
+		g = GravityCenter
+	}
+
+	return &crop{w: w, h: h, g: g}
+}
+
+func NewCropFromMap(m map[string]interface{}) (Filter, error) {
+	var l [4]Length
+	var a bool
+	for i, k := range []string{"x", "y", "width", "height"} {
+		v, ok, err := getLengthFromMap(k, m)
+		if err != nil {
+			return nil, err
+		}
+
+		l[i] = v
+		a = a || (ok && i < 2)
+	}
+
+	g, gok, err := parse.GetStringFromMap("gravity", m)
+	if err != nil {
+		return nil, err
+	}
+
+	if a && gok {
+		return nil, parse.Prefix(fmt.Errorf("a crop can't have both an offset and a gravity"), "gravity")
+	}
+	if a {
+		return NewCrop(l[0], l[1], l[2], l[3]), nil
+	}
+
+	_, _, err = gravitate(g, 0, 0, 0, 0)
+	if gok && g != GravitySmart && err != nil {
+		return nil, parse.Prefix(err, "gravity")
+	}
+
+	return NewGravityCrop(l[2], l[3], g), nil
+}
+
+func getLengthFromMap(k string, m map[string]interface{}) (Length, bool, error) {
+	o, ok, err := parse.GetInterfaceFromMap(k, m)
+	if !ok || err != nil {
+		return Length{}, ok, err
+	}
+
+	var v string
+	switch t := o.(type) {
+	case int:
+		v = strconv.Itoa(t)
+	case float64:
+		v = strconv.FormatFloat(t, 'f', -1, 64)
+	case string:
+		v = t
+	default:
+		return Length{}, true, parse.Prefix(fmt.Errorf("a value must be a number or a percentage, got %T", o), k)
+	}
+
+	l, err := ParseLength(v)
+	if err != nil {
+		return Length{}, true, parse.Prefix(err, k)
+	}
+
+	return l, true, nil
+}
+
+func cropRGBA(src *image.RGBA, x0, y0, x1, y1 int) (*image.RGBA, error) {
+	r := image.Rect(x0, y0, x1, y1).Intersect(src.Bounds())
+	if r.Empty() {
+		return nil, fmt.Errorf("the cropping area doesn't overlap the image")
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	for y := 0; y < r.Dy(); y++ {
+		s := src.PixOffset(r.Min.X, r.Min.Y+y)
+		copy(dst.Pix[y*dst.Stride:y*dst.Stride+r.Dx()*4], src.Pix[s:s+r.Dx()*4])
+	}
+
+	return dst, nil
+}
+
+func init() {
+	RegisterFilter(TypeCrop, NewCropFromMap)
+}
@@ -16,6 +16,7 @@ const GravitySouth string = "south"
 const GravitySouthEast string = "south_east"
 const GravitySouthWest string = "south_west"
 const GravityCenter string = "center"
+const GravitySmart string = "smart"
 
 var registered = map[string]func(m map[string]interface{}) (Filter, error){}
 
@@ -37,6 +38,31 @@ func (f Filters) Apply(img image.Image) (image.Image, error) {
 	return img, nil
 }
 
+func gravitate(g string, w, h, cw, ch int) (int, int, error) {
+	switch g {
+	case GravityEast:
+		return w - cw, (h - ch) / 2, nil
+	case GravityWest:
+		return 0, (h - ch) / 2, nil
+	case GravitySouth:
+		return (w - cw) / 2, h - ch, nil
+	case GravitySouthEast:
+		return w - cw, h - ch, nil
+	case GravitySouthWest:
+		return 0, h - ch, nil
+	case GravityNorth:
+		return (w - cw) / 2, 0, nil
+	case GravityNorthEast:
+		return w - cw, 0, nil
+	case GravityNorthWest:
+		return 0, 0, nil
+	case GravityCenter:
+		return (w - cw) / 2, (h - ch) / 2, nil
+	}
+
+	return 0, 0, errors.New("unexpected value of g")
+}
+
 func New(t string, m map[string]interface{}) (Filter, error) {
 	c, ok := registered[t]
 	if !ok {
@@ -62,7 +88,12 @@ func NewFromMap(m map[string]interface{}) (Filter, error) {
 		return nil, err
 	}
 
-	return New(t, m)
+	f, err := New(t, m)
+	if err != nil {
+		return nil, parse.Nest(err, "config")
+	}
+
+	return f, nil
 }
 
 func NewFromConfig(c interface{}) (Filter, error) {
@@ -3,7 +3,6 @@ package filter
 import (
 	"fmt"
 	"github.com/anthonynsimon/bild/clone"
-	"github.com/anthonynsimon/bild/transform"
 	"github.com/ueef/mosaic/pkg/parse"
 	"image"
 )
@@ -14,6 +13,7 @@ type overlay struct {
 	p  int
 	g  string
 	fi *image.RGBA
+	r  Resampler
 }
 
 func (f *overlay) Apply(img image.Image) (image.Image, error) {
@@ -104,10 +104,10 @@ func (f *overlay) fitForegroundImage(sw, sh int) *image.RGBA {
 		w = int(float32(h) * ff)
 	}
 
-	return transform.Resize(f.fi, w, h, transform.Linear)
+	return f.r.Resize(f.fi, w, h)
 }
 
-func NewOverlay(p int, g string, fi *image.RGBA) Filter {
+func NewOverlay(p int, g string, fi *image.RGBA, r Resampler) Filter {
 	if g == "" {
 		g = GravityCenter
 	}
@@ -116,6 +116,7 @@ func NewOverlay(p int, g string, fi *image.RGBA) Filter {
 		p:  p,
 		g:  g,
 		fi: fi,
+		r:  r,
 	}
 }
 
@@ -135,7 +136,12 @@ func NewOverlayFromMap(m map[string]interface{}) (Filter, error) {
 		return nil, err
 	}
 
-	return NewOverlay(p, g, fi), nil
+	r, err := NewResamplerFromMap(m)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewOverlay(p, g, fi, r), nil
 }
 
 func init() {
@@ -0,0 +1,59 @@
+package filter
+
+import (
+	"image"
+	"math"
+	"strconv"
+)
+
+const ParamFocusX = "focus_x"
+const ParamFocusY = "focus_y"
+
+type Params map[string]string
+
+func (p Params) Focus() (float64, float64, bool) {
+	x, err := strconv.ParseFloat(p[ParamFocusX], 64)
+	if err != nil || x < 0 || x > 1 {
+		return 0, 0, false
+	}
+
+	y, err := strconv.ParseFloat(p[ParamFocusY], 64)
+	if err != nil || y < 0 || y > 1 {
+		return 0, 0, false
+	}
+
+	return x, y, true
+}
+
+func (p Params) Merge(o Params) Params {
+	m := make(Params, len(p)+len(o))
+	for k, v := range p {
+		m[k] = v
+	}
+	for k, v := range o {
+		m[k] = v
+	}
+
+	return m
+}
+
+type ParamFilter interface {
+	Filter
+	ApplyParams(img image.Image, p Params) (image.Image, error)
+}
+
+func place(img *image.RGBA, g string, p Params, cw, ch int) (int, int, error) {
+	b := img.Bounds()
+	if fx, fy, ok := p.Focus(); ok {
+		x := int(math.Round(fx*float64(b.Dx()) - float64(cw)/2))
+		y := int(math.Round(fy*float64(b.Dy()) - float64(ch)/2))
+
+		return clampInt(x, 0, b.Dx()-cw), clampInt(y, 0, b.Dy()-ch), nil
+	}
+	if g == GravitySmart {
+		x, y := smartCrop(img, cw, ch)
+		return x, y, nil
+	}
+
+	return gravitate(g, b.Dx(), b.Dy(), cw, ch)
+}
@@ -0,0 +1,214 @@
+package filter
+
+import (
+	"fmt"
+	"github.com/anthonynsimon/bild/clone"
+	"github.com/anthonynsimon/bild/transform"
+	"github.com/ueef/mosaic/pkg/parse"
+	"image"
+	"math"
+)
+
+const ResamplingNearest = "nearest"
+const ResamplingLinear = "linear"
+const ResamplingCatmullRom = "catmull-rom"
+const ResamplingLanczos = "lanczos"
+const ResamplingMitchell = "mitchell"
+
+var DefaultResampler = Resampler{Kernel: transform.Linear}
+
+var toLinear = newToLinearTable()
+var fromLinear = newFromLinearTable()
+
+type Resampler struct {
+	Kernel      transform.ResampleFilter
+	LinearLight bool
+}
+
+func (r Resampler) Resize(img image.Image, w, h int) *image.RGBA {
+	if !r.LinearLight || r.Kernel.Support <= 0 || w <= 0 || h <= 0 || img.Bounds().Empty() {
+		return transform.Resize(img, w, h, r.Kernel)
+	}
+
+	src := clone.AsRGBA(img)
+	b := src.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+
+	l := make([]float32, sw*sh*4)
+	for y := 0; y < sh; y++ {
+		p := src.PixOffset(b.Min.X, b.Min.Y+y)
+		for x := 0; x < sw; x++ {
+			s, d := src.Pix[p+x*4:p+x*4+4], l[(y*sw+x)*4:(y*sw+x)*4+4]
+			a := s[3]
+			if a == 0 {
+				continue
+			}
+
+			f := float32(a) / 255
+			for c := 0; c < 3; c++ {
+				d[c] = toLinear[unpremultiply(s[c], a)] * f
+			}
+			d[3] = f
+		}
+	}
+
+	l = r.resample(l, sw, sh, w, true)
+	l = r.resample(l, sh, w, h, false)
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := 0; i < len(l); i += 4 {
+		a := l[i+3]
+		if a <= 0 {
+			continue
+		}
+		if a > 1 {
+			a = 1
+		}
+
+		for c := 0; c < 3; c++ {
+			v := l[i+c] / a
+			if v < 0 {
+				v = 0
+			} else if v > 1 {
+				v = 1
+			}
+			dst.Pix[i+c] = uint8(float32(fromLinear[int(v*4095+0.5)])*a + 0.5)
+		}
+		dst.Pix[i+3] = uint8(a*255 + 0.5)
+	}
+
+	return dst
+}
+
+// resample scales n lines of sl samples to dl samples, lines are rows of an
+// image if h is set and its columns otherwise.
+func (r Resampler) resample(src []float32, sl, n, dl int, h bool) []float32 {
+	at := func(j, s, l int) int {
+		if h {
+			return (j*l + s) * 4
+		}
+
+		return (s*n + j) * 4
+	}
+
+	sc := float64(sl) / float64(dl)
+	fs := math.Max(sc, 1)
+	su := r.Kernel.Support * fs
+
+	dst := make([]float32, n*dl*4)
+	for i := 0; i < dl; i++ {
+		c := (float64(i)+0.5)*sc - 0.5
+		s0 := int(math.Ceil(c - su))
+		s1 := int(math.Floor(c + su))
+
+		ws := make([]float32, 0, s1-s0+1)
+		var t float64
+		for s := s0; s <= s1; s++ {
+			w := r.Kernel.Fn((float64(s) - c) / fs)
+			ws = append(ws, float32(w))
+			t += w
+		}
+		if t != 0 {
+			for k := range ws {
+				ws[k] /= float32(t)
+			}
+		}
+
+		for j := 0; j < n; j++ {
+			d := dst[at(j, i, dl) : at(j, i, dl)+4]
+			for k, w := range ws {
+				s := s0 + k
+				if s < 0 {
+					s = 0
+				} else if s >= sl {
+					s = sl - 1
+				}
+
+				p := src[at(j, s, sl) : at(j, s, sl)+4]
+				d[0] += p[0] * w
+				d[1] += p[1] * w
+				d[2] += p[2] * w
+				d[3] += p[3] * w
+			}
+		}
+	}
+
+	return dst
+}
+
+func NewResampler(k string, linearLight bool) (Resampler, error) {
+	r := Resampler{LinearLight: linearLight}
+	switch k {
+	case ResamplingNearest:
+		r.Kernel = transform.NearestNeighbor
+	case ResamplingLinear, "":
+		r.Kernel = transform.Linear
+	case ResamplingCatmullRom:
+		r.Kernel = transform.CatmullRom
+	case ResamplingLanczos:
+		r.Kernel = transform.Lanczos
+	case ResamplingMitchell:
+		r.Kernel = transform.MitchellNetravali
+	default:
+		return r, fmt.Errorf("a resampling \"%s\" is undefined", k)
+	}
+
+	return r, nil
+}
+
+func NewResamplerFromMap(m map[string]interface{}) (Resampler, error) {
+	k, _, err := parse.GetStringFromMap("resampling", m)
+	if err != nil {
+		return Resampler{}, err
+	}
+
+	l, _, err := parse.GetBoolFromMap("linear_light", m)
+	if err != nil {
+		return Resampler{}, err
+	}
+
+	return NewResampler(k, l)
+}
+
+func unpremultiply(c, a uint8) uint8 {
+	if a == 0xff {
+		return c
+	}
+
+	v := (int(c)*255 + int(a)/2) / int(a)
+	if v > 255 {
+		return 255
+	}
+
+	return uint8(v)
+}
+
+func newToLinearTable() *[256]float32 {
+	t := &[256]float32{}
+	for i := range t {
+		v := float64(i) / 255
+		if v <= 0.04045 {
+			v /= 12.92
+		} else {
+			v = math.Pow((v+0.055)/1.055, 2.4)
+		}
+		t[i] = float32(v)
+	}
+
+	return t
+}
+
+func newFromLinearTable() *[4096]uint8 {
+	t := &[4096]uint8{}
+	for i := range t {
+		v := float64(i) / 4095
+		if v <= 0.0031308 {
+			v *= 12.92
+		} else {
+			v = 1.055*math.Pow(v, 1/2.4) - 0.055
+		}
+		t[i] = uint8(math.Round(v * 255))
+	}
+
+	return t
+}
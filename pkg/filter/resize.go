@@ -1,26 +1,57 @@
 package filter
 
 import (
-	"github.com/anthonynsimon/bild/transform"
+	"fmt"
+	"github.com/anthonynsimon/bild/clone"
 	"github.com/ueef/mosaic/pkg/parse"
 	"image"
+	"image/color"
+	"image/draw"
+	"math"
 )
 
 const TypeResize = "resize"
 
+const ResizeFit = "fit"
+const ResizeFill = "fill"
+const ResizePad = "pad"
+const ResizeStretch = "stretch"
+
 type resize struct {
-	w int
-	h int
+	w  int
+	h  int
+	r  Resampler
+	m  string
+	u  bool
+	g  string
+	bg color.Color
 }
 
 func (f resize) Apply(img image.Image) (image.Image, error) {
+	return f.ApplyParams(img, nil)
+}
+
+func (f resize) ApplyParams(img image.Image, p Params) (image.Image, error) {
+	switch f.m {
+	case ResizeFill:
+		return f.fill(img, p)
+	case ResizePad:
+		return f.pad(img)
+	case ResizeStretch:
+		return f.stretch(img), nil
+	}
+
+	return f.fit(img), nil
+}
+
+func (f resize) fit(img image.Image) image.Image {
 	if f.w == 0 && f.h == 0 {
-		return img, nil
+		return img
 	}
 
 	b := img.Bounds()
-	if (f.w == 0 || b.Dx() < f.w) && (f.h == 0 || b.Dy() < f.h) {
-		return img, nil
+	if !f.u && (f.w == 0 || b.Dx() < f.w) && (f.h == 0 || b.Dy() < f.h) {
+		return img
 	}
 
 	fr := float32(b.Dx()) / float32(b.Dy())
@@ -37,13 +68,94 @@ func (f resize) Apply(img image.Image) (image.Image, error) {
 		}
 	}
 
-	img = transform.Resize(img, w, h, transform.Linear)
+	return f.r.Resize(img, w, h)
+}
+
+func (f resize) fill(img image.Image, p Params) (image.Image, error) {
+	b := img.Bounds()
+	w, h := f.scale(b, math.Max(float64(f.w)/float64(b.Dx()), float64(f.h)/float64(b.Dy())))
+	src := f.resize(img, w, h)
+
+	cw, ch := minInt(f.w, w), minInt(f.h, h)
+	x, y, err := place(src, f.g, p, cw, ch)
+	if err != nil {
+		return nil, err
+	}
+
+	o := src.Bounds().Min
+
+	return cropRGBA(src, o.X+x, o.Y+y, o.X+x+cw, o.Y+y+ch)
+}
+
+func (f resize) pad(img image.Image) (image.Image, error) {
+	b := img.Bounds()
+	w, h := f.scale(b, math.Min(float64(f.w)/float64(b.Dx()), float64(f.h)/float64(b.Dy())))
+
+	src := f.resize(img, w, h)
+
+	x, y, err := gravitate(f.g, f.w, f.h, w, h)
+	if err != nil {
+		return nil, err
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, f.w, f.h))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(f.bg), image.Point{}, draw.Src)
+	draw.Draw(dst, image.Rect(x, y, x+w, y+h), src, src.Bounds().Min, draw.Over)
+
+	return dst, nil
+}
+
+func (f resize) stretch(img image.Image) image.Image {
+	b := img.Bounds()
+	w, h := f.w, f.h
+	if !f.u {
+		w, h = minInt(w, b.Dx()), minInt(h, b.Dy())
+	}
+	if w == b.Dx() && h == b.Dy() {
+		return img
+	}
+
+	return f.r.Resize(img, w, h)
+}
+
+func (f resize) resize(img image.Image, w, h int) *image.RGBA {
+	b := img.Bounds()
+	if w == b.Dx() && h == b.Dy() {
+		return clone.AsRGBA(img)
+	}
+
+	return f.r.Resize(img, w, h)
+}
+
+func (f resize) scale(b image.Rectangle, s float64) (int, int) {
+	if !f.u && s > 1 {
+		s = 1
+	}
+
+	w, h := int(math.Round(float64(b.Dx())*s)), int(math.Round(float64(b.Dy())*s))
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
 
-	return img, nil
+	return w, h
 }
 
-func NewResize(w, h int) Filter {
-	return &resize{w, h}
+func NewResize(w, h int, r Resampler) Filter {
+	return &resize{w: w, h: h, r: r, m: ResizeFit}
+}
+
+func NewModeResize(w, h int, r Resampler, m string, u bool, g string, bg color.Color) Filter {
+	if g == "" {
+		g = GravityCenter
+	}
+	if bg == nil {
+		bg = color.Transparent
+	}
+
+	return &resize{w, h, r, m, u, g, bg}
 }
 
 func NewResizeFromMap(m map[string]interface{}) (Filter, error) {
@@ -57,7 +169,50 @@ func NewResizeFromMap(m map[string]interface{}) (Filter, error) {
 		return nil, err
 	}
 
-	return NewResize(width, height), nil
+	r, err := NewResamplerFromMap(m)
+	if err != nil {
+		return nil, err
+	}
+
+	md, ok, err := parse.GetStringFromMap("mode", m)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		md = ResizeFit
+	}
+
+	switch md {
+	case ResizeFit:
+	case ResizeFill, ResizePad, ResizeStretch:
+		if width <= 0 || height <= 0 {
+			return nil, fmt.Errorf("a resize in the mode \"%s\" must have a positive width and height", md)
+		}
+	default:
+		return nil, fmt.Errorf("a resize mode \"%s\" is undefined", md)
+	}
+
+	u, _, err := parse.GetBoolFromMap("upscale", m)
+	if err != nil {
+		return nil, err
+	}
+
+	g, gok, err := parse.GetStringFromMap("gravity", m)
+	if err != nil {
+		return nil, err
+	}
+
+	_, _, err = gravitate(g, 0, 0, 0, 0)
+	if gok && err != nil && (g != GravitySmart || md != ResizeFill) {
+		return nil, parse.Prefix(fmt.Errorf("a gravity \"%s\" is undefined for a resize in the mode \"%s\"", g, md), "gravity")
+	}
+
+	bg, _, err := parse.GetColorFromMap("background", m)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewModeResize(width, height, r, md, u, g, bg), nil
 }
 
 func init() {
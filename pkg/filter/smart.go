@@ -0,0 +1,122 @@
+package filter
+
+import (
+	"image"
+	"math"
+)
+
+const smartCells = 128
+const smartEdgeWeight = 1.0
+const smartSkinWeight = 1.8
+const smartSaturationWeight = 0.3
+
+var skinColor = [3]float64{0.78, 0.57, 0.44}
+
+func smartCrop(img *image.RGBA, cw, ch int) (int, int) {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if cw >= w && ch >= h {
+		return 0, 0
+	}
+
+	cs := w
+	if h > cs {
+		cs = h
+	}
+	cs = (cs + smartCells - 1) / smartCells
+	gw, gh := (w+cs-1)/cs, (h+cs-1)/cs
+
+	e := threshold(img)
+	sc := make([]float64, (gw+1)*(gh+1))
+	for y := 0; y < h; y++ {
+		p := img.PixOffset(b.Min.X, b.Min.Y+y)
+		for x := 0; x < w; x++ {
+			r, g, bl := float64(img.Pix[p])/255, float64(img.Pix[p+1])/255, float64(img.Pix[p+2])/255
+			p += 4
+
+			v := float64(e[y*w+x]) / 255 * smartEdgeWeight
+			v += skin(r, g, bl) * smartSkinWeight
+			v += saturation(r, g, bl) * smartSaturationWeight
+			sc[(y/cs+1)*(gw+1)+x/cs+1] += v
+		}
+	}
+
+	for y := 1; y <= gh; y++ {
+		for x := 1; x <= gw; x++ {
+			sc[y*(gw+1)+x] += sc[(y-1)*(gw+1)+x] + sc[y*(gw+1)+x-1] - sc[(y-1)*(gw+1)+x-1]
+		}
+	}
+
+	ww, wh := minInt(gw, (cw+cs/2)/cs), minInt(gh, (ch+cs/2)/cs)
+	bx, by, bs, bd := 0, 0, -1.0, 0.0
+	for y := 0; y <= gh-wh; y++ {
+		for x := 0; x <= gw-ww; x++ {
+			s := sc[(y+wh)*(gw+1)+x+ww] - sc[y*(gw+1)+x+ww] - sc[(y+wh)*(gw+1)+x] + sc[y*(gw+1)+x]
+			d := math.Hypot(float64(x*2+ww-gw), float64(y*2+wh-gh))
+			if s > bs || (s == bs && d < bd) {
+				bx, by, bs, bd = x, y, s, d
+			}
+		}
+	}
+
+	return clampInt(bx*cs, 0, w-cw), clampInt(by*cs, 0, h-ch)
+}
+
+func skin(r, g, b float64) float64 {
+	l := math.Sqrt(r*r + g*g + b*b)
+	if l < 0.2 {
+		return 0
+	}
+
+	d := math.Sqrt((r/l-skinColor[0])*(r/l-skinColor[0]) + (g/l-skinColor[1])*(g/l-skinColor[1]) + (b/l-skinColor[2])*(b/l-skinColor[2]))
+	s := 1 - d
+	if s < 0.8 {
+		return 0
+	}
+
+	return (s - 0.8) / 0.2
+}
+
+func saturation(r, g, b float64) float64 {
+	max := math.Max(r, math.Max(g, b))
+	min := math.Min(r, math.Min(g, b))
+	if max == min {
+		return 0
+	}
+
+	l := (max + min) / 2
+	if l < 0.05 || l > 0.9 {
+		return 0
+	}
+
+	var s float64
+	if l > 0.5 {
+		s = (max - min) / (2 - max - min)
+	} else {
+		s = (max - min) / (max + min)
+	}
+	if s < 0.4 {
+		return 0
+	}
+
+	return (s - 0.4) / 0.6
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+func clampInt(v, min, max int) int {
+	if v > max {
+		v = max
+	}
+	if v < min {
+		v = min
+	}
+
+	return v
+}
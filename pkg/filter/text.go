@@ -80,7 +80,7 @@ func NewTextFromMap(m map[string]interface{}) (Filter, error) {
 	}
 	s, err := stamp.NewFromMap(sm)
 	if err != nil {
-		return nil, err
+		return nil, parse.Nest(err, "stamp")
 	}
 
 	tc, err := parse.GetRequiredColorFromMap("text_color", m)
@@ -465,7 +465,7 @@ func newGrid(cs int, img *image.RGBA) *grid {
 		loop:
 			for y := miny; y < maxy; y++ {
 				for x := minx; x < maxx; x++ {
-					si := y*w + x
+					si := (y-b.Min.Y)*w + x - b.Min.X
 					if s[si] > 0 {
 						g[gy*gw+gx] = true
 						break loop
@@ -494,8 +494,8 @@ func threshold(img *image.RGBA) []int {
 	a := w * h
 	s := make([]int, a)
 	for y := b.Min.Y; y < b.Max.Y; y++ {
-		i := y * w
-		pi := y*img.Stride + b.Min.X*4
+		i := (y - b.Min.Y) * w
+		pi := img.PixOffset(b.Min.X, y)
 		for x := b.Min.X; x < b.Max.X; x++ {
 			s[i] = math.MaxUint8 - int(0.299*float64(img.Pix[pi+0])+0.587*float64(img.Pix[pi+1])+0.114*float64(img.Pix[pi+2]))
 			i++
@@ -530,7 +530,7 @@ func threshold(img *image.RGBA) []int {
 			min, max, avg := math.MaxUint8, 0, 0
 			for y := miny; y < maxy; y++ {
 				for x := minx; x < maxx; x++ {
-					si := y*w + x
+					si := (y-b.Min.Y)*w + x - b.Min.X
 					if s[si] < min {
 						min = s[si]
 					}
@@ -546,7 +546,7 @@ func threshold(img *image.RGBA) []int {
 			avg += d / 2
 			for y := miny; y < maxy; y++ {
 				for x := minx; x < maxx; x++ {
-					si := y*w + x
+					si := (y-b.Min.Y)*w + x - b.Min.X
 
 					if d < 32 {
 						s[si] = 0
@@ -0,0 +1,212 @@
+package icc
+
+import (
+	"github.com/ueef/mosaic/pkg/utils"
+	"image"
+	"math"
+)
+
+var xyzToSRGB = [3][3]float64{
+	{3.1338561, -1.6168667, -0.4906146},
+	{-0.9787684, 1.9161415, 0.0334540},
+	{0.0719453, -0.2289914, 1.4052427},
+}
+
+const outputLevels = 4096
+
+var outputTable = newOutputTable()
+
+type transform struct {
+	in [3][256]float64
+	m  [3][3]float64
+}
+
+func (p *Profile) transform() *transform {
+	t := &transform{}
+	for c := 0; c < 3; c++ {
+		for i := 0; i < 256; i++ {
+			t.in[c][i] = p.c[c](float64(i) / 255)
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			for k := 0; k < 3; k++ {
+				t.m[i][j] += xyzToSRGB[i][k] * p.m[k][j]
+			}
+		}
+	}
+
+	return t
+}
+
+func (t *transform) apply(r, g, b uint8) (uint8, uint8, uint8) {
+	l := [3]float64{t.in[0][r], t.in[1][g], t.in[2][b]}
+
+	var o [3]uint8
+	for i := range o {
+		o[i] = encode(t.m[i][0]*l[0] + t.m[i][1]*l[1] + t.m[i][2]*l[2])
+	}
+
+	return o[0], o[1], o[2]
+}
+
+func (t *transform) isIdentity() bool {
+	for v := 0; v < 256; v += 15 {
+		c := uint8(v)
+		for _, p := range [][3]uint8{{c, 0, 0}, {0, c, 0}, {0, 0, c}, {c, c, c}} {
+			r, g, b := t.apply(p[0], p[1], p[2])
+			if diff(r, p[0]) > 1 || diff(g, p[1]) > 1 || diff(b, p[2]) > 1 {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func (p *Profile) ToSRGB(img image.Image) (image.Image, error) {
+	if p.IsCMYK() {
+		c, ok := img.(*image.CMYK)
+		if !ok {
+			return nil, ErrUnsupported
+		}
+
+		return p.cmykToSRGB(c), nil
+	}
+	if _, ok := img.(*image.CMYK); ok {
+		return nil, ErrUnsupported
+	}
+
+	t := p.transform()
+	if t.isIdentity() {
+		return img, nil
+	}
+
+	src := utils.ConvertToRGBA(img)
+	b := src.Bounds()
+	dst := image.NewRGBA(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		s := src.Pix[src.PixOffset(b.Min.X, y):src.PixOffset(b.Max.X, y)]
+		d := dst.Pix[dst.PixOffset(b.Min.X, y):dst.PixOffset(b.Max.X, y)]
+		for i := 0; i < len(s); i += 4 {
+			a := s[i+3]
+			if a == 0 {
+				continue
+			}
+
+			r, g, bl := s[i], s[i+1], s[i+2]
+			if a != 0xff {
+				r, g, bl = unmultiply(r, a), unmultiply(g, a), unmultiply(bl, a)
+			}
+
+			r, g, bl = t.apply(r, g, bl)
+			if a != 0xff {
+				r, g, bl = multiply(r, a), multiply(g, a), multiply(bl, a)
+			}
+
+			d[i], d[i+1], d[i+2], d[i+3] = r, g, bl, a
+		}
+	}
+
+	return dst, nil
+}
+
+func (p *Profile) cmykToSRGB(src *image.CMYK) *image.RGBA {
+	c := map[uint32][3]uint8{}
+	in := make([]float64, 4)
+
+	return convertCMYK(src, func(s []uint8) (uint8, uint8, uint8) {
+		k := uint32(s[0])<<24 | uint32(s[1])<<16 | uint32(s[2])<<8 | uint32(s[3])
+		if v, ok := c[k]; ok {
+			return v[0], v[1], v[2]
+		}
+
+		if len(c) >= 1<<16 {
+			c = map[uint32][3]uint8{}
+		}
+
+		for i := range in {
+			in[i] = float64(s[i]) / 255
+		}
+		x := p.pcs(p.a2b.eval(in))
+
+		var v [3]uint8
+		for i := range v {
+			v[i] = encode(xyzToSRGB[i][0]*x[0] + xyzToSRGB[i][1]*x[1] + xyzToSRGB[i][2]*x[2])
+		}
+		c[k] = v
+
+		return v[0], v[1], v[2]
+	})
+}
+
+func CMYKToRGBA(src *image.CMYK) *image.RGBA {
+	return convertCMYK(src, func(s []uint8) (uint8, uint8, uint8) {
+		w := 255 - uint32(s[3])
+
+		return uint8((255 - uint32(s[0])) * w / 255), uint8((255 - uint32(s[1])) * w / 255), uint8((255 - uint32(s[2])) * w / 255)
+	})
+}
+
+func convertCMYK(src *image.CMYK, f func(s []uint8) (uint8, uint8, uint8)) *image.RGBA {
+	b := src.Bounds()
+	dst := image.NewRGBA(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		s := src.Pix[src.PixOffset(b.Min.X, y):src.PixOffset(b.Max.X, y)]
+		d := dst.Pix[dst.PixOffset(b.Min.X, y):dst.PixOffset(b.Max.X, y)]
+		for i := 0; i < len(s); i += 4 {
+			d[i], d[i+1], d[i+2] = f(s[i : i+4])
+			d[i+3] = 0xff
+		}
+	}
+
+	return dst
+}
+
+func newOutputTable() *[outputLevels + 1]uint8 {
+	t := &[outputLevels + 1]uint8{}
+	for i := range t {
+		v := float64(i) / outputLevels
+		if v <= 0.0031308 {
+			v *= 12.92
+		} else {
+			v = 1.055*math.Pow(v, 1/2.4) - 0.055
+		}
+		t[i] = uint8(math.Round(v * 255))
+	}
+
+	return t
+}
+
+func encode(v float64) uint8 {
+	switch {
+	case v <= 0:
+		return outputTable[0]
+	case v >= 1:
+		return outputTable[outputLevels]
+	}
+
+	return outputTable[int(v*outputLevels+0.5)]
+}
+
+func unmultiply(c, a uint8) uint8 {
+	v := (int(c)*255 + int(a)/2) / int(a)
+	if v > 255 {
+		return 255
+	}
+
+	return uint8(v)
+}
+
+func multiply(c, a uint8) uint8 {
+	return uint8((int(c)*int(a) + 127) / 255)
+}
+
+func diff(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+
+	return b - a
+}
@@ -0,0 +1,195 @@
+package icc
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+type Mode string
+
+const ModeConvert Mode = "convert"
+const ModeKeep Mode = "keep"
+
+var ErrUnsupported = errors.New("the icc profile isn't supported")
+
+type curve func(v float64) float64
+
+type Profile struct {
+	m   [3][3]float64
+	c   [3]curve
+	a2b *lut
+	pcs func(v [3]float64) [3]float64
+}
+
+func (p *Profile) IsCMYK() bool {
+	return p.a2b != nil
+}
+
+func Parse(b []byte) (*Profile, error) {
+	if len(b) < 132 || string(b[36:40]) != "acsp" {
+		return nil, errors.New("a data isn't an icc profile")
+	}
+	cs, pcs := string(b[16:20]), string(b[20:24])
+	if pcs != "XYZ " && pcs != "Lab " {
+		return nil, ErrUnsupported
+	}
+
+	t := map[string][]byte{}
+	n := int(binary.BigEndian.Uint32(b[128:]))
+	for i := 0; i < n && 132+i*12+12 <= len(b); i++ {
+		e := b[132+i*12:]
+		o := int(binary.BigEndian.Uint32(e[4:]))
+		l := int(binary.BigEndian.Uint32(e[8:]))
+		if o < 0 || l < 0 || o+l > len(b) {
+			return nil, errors.New("an icc profile is malformed")
+		}
+		t[string(e[:4])] = b[o : o+l]
+	}
+
+	if cs == "CMYK" {
+		return parseCMYK(t["A2B0"], pcs == "Lab ")
+	}
+	if cs != "RGB " || pcs != "XYZ " {
+		return nil, ErrUnsupported
+	}
+
+	p := &Profile{}
+	for i, s := range []string{"rXYZ", "gXYZ", "bXYZ"} {
+		x, err := parseXYZ(t[s])
+		if err != nil {
+			return nil, err
+		}
+		p.m[0][i], p.m[1][i], p.m[2][i] = x[0], x[1], x[2]
+	}
+	for i, s := range []string{"rTRC", "gTRC", "bTRC"} {
+		c, _, err := parseCurve(t[s])
+		if err != nil {
+			return nil, err
+		}
+		p.c[i] = c
+	}
+
+	return p, nil
+}
+
+func ParseMode(s string) (Mode, error) {
+	switch m := Mode(s); m {
+	case ModeConvert, ModeKeep:
+		return m, nil
+	}
+
+	return "", errors.New("an icc mode \"" + s + "\" is undefined")
+}
+
+func parseXYZ(b []byte) ([3]float64, error) {
+	var x [3]float64
+	if len(b) < 20 || string(b[:4]) != "XYZ " {
+		return x, ErrUnsupported
+	}
+
+	for i := range x {
+		x[i] = s15Fixed16(b[8+i*4:])
+	}
+
+	return x, nil
+}
+
+func parseCurve(b []byte) (curve, int, error) {
+	if len(b) < 12 {
+		return nil, 0, ErrUnsupported
+	}
+
+	switch string(b[:4]) {
+	case "curv":
+		n := int(binary.BigEndian.Uint32(b[8:]))
+		if len(b) < 12+n*2 {
+			return nil, 0, ErrUnsupported
+		}
+		l := (12 + n*2 + 3) &^ 3
+
+		switch n {
+		case 0:
+			return func(v float64) float64 { return v }, l, nil
+		case 1:
+			g := float64(binary.BigEndian.Uint16(b[12:])) / 256
+			return func(v float64) float64 { return math.Pow(v, g) }, l, nil
+		}
+
+		t := make([]float64, n)
+		for i := range t {
+			t[i] = float64(binary.BigEndian.Uint16(b[12+i*2:])) / 65535
+		}
+
+		return tableCurve(t), l, nil
+	case "para":
+		f := int(binary.BigEndian.Uint16(b[8:]))
+		np := [...]int{1, 3, 4, 5, 7}
+		if f >= len(np) || len(b) < 12+np[f]*4 {
+			return nil, 0, ErrUnsupported
+		}
+		l := 12 + np[f]*4
+
+		var a [7]float64
+		for i := 0; i < np[f]; i++ {
+			a[i] = s15Fixed16(b[12+i*4:])
+		}
+
+		g := a[0]
+		switch f {
+		case 0:
+			return func(v float64) float64 { return math.Pow(v, g) }, l, nil
+		case 1:
+			return func(v float64) float64 {
+				if v >= -a[2]/a[1] {
+					return math.Pow(a[1]*v+a[2], g)
+				}
+				return 0
+			}, l, nil
+		case 2:
+			return func(v float64) float64 {
+				if v >= -a[2]/a[1] {
+					return math.Pow(a[1]*v+a[2], g) + a[3]
+				}
+				return a[3]
+			}, l, nil
+		case 3:
+			return func(v float64) float64 {
+				if v >= a[4] {
+					return math.Pow(a[1]*v+a[2], g)
+				}
+				return a[3] * v
+			}, l, nil
+		}
+
+		return func(v float64) float64 {
+			if v >= a[4] {
+				return math.Pow(a[1]*v+a[2], g) + a[5]
+			}
+			return a[3]*v + a[6]
+		}, l, nil
+	}
+
+	return nil, 0, ErrUnsupported
+}
+
+func tableCurve(t []float64) curve {
+	n := len(t)
+
+	return func(v float64) float64 {
+		p := v * float64(n-1)
+		i := int(p)
+		if i >= n-1 {
+			return t[n-1]
+		}
+		if i < 0 {
+			return t[0]
+		}
+
+		return t[i] + (t[i+1]-t[i])*(p-float64(i))
+	}
+}
+
+func s15Fixed16(b []byte) float64 {
+	return float64(int32(binary.BigEndian.Uint32(b))) / 65536
+}
@@ -0,0 +1,340 @@
+package icc
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+type lut struct {
+	a    []curve
+	grid []int
+	clut []float64
+	m    []curve
+	mx   *[12]float64
+	b    []curve
+}
+
+func (l *lut) eval(in []float64) [3]float64 {
+	v := make([]float64, len(in))
+	for i := range in {
+		v[i] = clamp(in[i])
+		if l.a != nil {
+			v[i] = clamp(l.a[i](v[i]))
+		}
+	}
+
+	var o [3]float64
+	if l.clut != nil {
+		l.interpolate(v, o[:])
+	} else {
+		copy(o[:], v)
+	}
+
+	if l.m != nil {
+		for i := range o {
+			o[i] = clamp(l.m[i](clamp(o[i])))
+		}
+	}
+	if l.mx != nil {
+		x := *l.mx
+		o = [3]float64{
+			x[0]*o[0] + x[1]*o[1] + x[2]*o[2] + x[9],
+			x[3]*o[0] + x[4]*o[1] + x[5]*o[2] + x[10],
+			x[6]*o[0] + x[7]*o[1] + x[8]*o[2] + x[11],
+		}
+	}
+	for i := range o {
+		o[i] = clamp(l.b[i](clamp(o[i])))
+	}
+
+	return o
+}
+
+func (l *lut) interpolate(v []float64, o []float64) {
+	n := len(v)
+	base := make([]int, n)
+	frac := make([]float64, n)
+	for i := range v {
+		p := v[i] * float64(l.grid[i]-1)
+		base[i] = int(p)
+		if base[i] >= l.grid[i]-1 {
+			base[i] = l.grid[i] - 2
+			if base[i] < 0 {
+				base[i] = 0
+			}
+		}
+		frac[i] = p - float64(base[i])
+	}
+
+	for c := 0; c < 1<<uint(n); c++ {
+		w := 1.0
+		k := 0
+		for i := 0; i < n; i++ {
+			g := base[i]
+			if c>>uint(n-1-i)&1 == 1 {
+				g++
+				w *= frac[i]
+			} else {
+				w *= 1 - frac[i]
+			}
+			if g >= l.grid[i] {
+				g = l.grid[i] - 1
+			}
+			k = k*l.grid[i] + g
+		}
+		if w == 0 {
+			continue
+		}
+
+		for j := range o {
+			o[j] += w * l.clut[k*3+j]
+		}
+	}
+}
+
+func parseCMYK(b []byte, lab bool) (*Profile, error) {
+	if len(b) < 32 {
+		return nil, ErrUnsupported
+	}
+
+	var l *lut
+	var err error
+	var legacy bool
+	switch string(b[:4]) {
+	case "mft1":
+		l, err = parseLut8(b)
+	case "mft2":
+		l, err = parseLut16(b)
+		legacy = true
+	case "mAB ":
+		l, err = parseLutAToB(b)
+	default:
+		return nil, ErrUnsupported
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Profile{a2b: l}
+	switch {
+	case lab && legacy:
+		p.pcs = func(v [3]float64) [3]float64 {
+			return labToXYZ(v[0]*65535/65280*100, v[1]*65535/256-128, v[2]*65535/256-128)
+		}
+	case lab:
+		p.pcs = func(v [3]float64) [3]float64 {
+			return labToXYZ(v[0]*100, v[1]*255-128, v[2]*255-128)
+		}
+	default:
+		p.pcs = func(v [3]float64) [3]float64 {
+			return [3]float64{v[0] * 65535 / 32768, v[1] * 65535 / 32768, v[2] * 65535 / 32768}
+		}
+	}
+
+	return p, nil
+}
+
+func parseLut8(b []byte) (*lut, error) {
+	i, o, g := int(b[8]), int(b[9]), int(b[10])
+	if i != 4 || o != 3 || g < 2 {
+		return nil, ErrUnsupported
+	}
+
+	n := pow(g, i) * o
+	if len(b) < 48+i*256+n+o*256 {
+		return nil, ErrUnsupported
+	}
+
+	p := 48
+	l := &lut{
+		grid: []int{g, g, g, g},
+		clut: make([]float64, n),
+	}
+	l.a, p = lut8Curves(b, p, i)
+	for k := range l.clut {
+		l.clut[k] = float64(b[p+k]) / 255
+	}
+	p += n
+	l.b, _ = lut8Curves(b, p, o)
+
+	return l, nil
+}
+
+func lut8Curves(b []byte, p, n int) ([]curve, int) {
+	c := make([]curve, n)
+	for i := range c {
+		t := make([]float64, 256)
+		for k := range t {
+			t[k] = float64(b[p+k]) / 255
+		}
+		c[i] = tableCurve(t)
+		p += 256
+	}
+
+	return c, p
+}
+
+func parseLut16(b []byte) (*lut, error) {
+	if len(b) < 52 {
+		return nil, ErrUnsupported
+	}
+
+	i, o, g := int(b[8]), int(b[9]), int(b[10])
+	ie, oe := int(binary.BigEndian.Uint16(b[48:])), int(binary.BigEndian.Uint16(b[50:]))
+	if i != 4 || o != 3 || g < 2 || ie < 2 || oe < 2 {
+		return nil, ErrUnsupported
+	}
+
+	n := pow(g, i) * o
+	if len(b) < 52+(i*ie+n+o*oe)*2 {
+		return nil, ErrUnsupported
+	}
+
+	p := 52
+	l := &lut{
+		grid: []int{g, g, g, g},
+		clut: make([]float64, n),
+	}
+	l.a, p = lut16Curves(b, p, i, ie)
+	for k := range l.clut {
+		l.clut[k] = float64(binary.BigEndian.Uint16(b[p+k*2:])) / 65535
+	}
+	p += n * 2
+	l.b, _ = lut16Curves(b, p, o, oe)
+
+	return l, nil
+}
+
+func lut16Curves(b []byte, p, n, e int) ([]curve, int) {
+	c := make([]curve, n)
+	for i := range c {
+		t := make([]float64, e)
+		for k := range t {
+			t[k] = float64(binary.BigEndian.Uint16(b[p+k*2:])) / 65535
+		}
+		c[i] = tableCurve(t)
+		p += e * 2
+	}
+
+	return c, p
+}
+
+func parseLutAToB(b []byte) (*lut, error) {
+	i, o := int(b[8]), int(b[9])
+	if i != 4 || o != 3 {
+		return nil, ErrUnsupported
+	}
+
+	off := func(k int) int {
+		return int(binary.BigEndian.Uint32(b[12+k*4:]))
+	}
+
+	var err error
+	l := &lut{}
+	if l.b, err = curves(b, off(0), o); err != nil || l.b == nil {
+		return nil, ErrUnsupported
+	}
+	if p := off(1); p != 0 {
+		if p+48 > len(b) {
+			return nil, ErrUnsupported
+		}
+
+		l.mx = &[12]float64{}
+		for k := range l.mx {
+			l.mx[k] = s15Fixed16(b[p+k*4:])
+		}
+	}
+	if l.m, err = curves(b, off(2), o); err != nil {
+		return nil, err
+	}
+	if l.a, err = curves(b, off(4), i); err != nil {
+		return nil, err
+	}
+
+	p := off(3)
+	if p == 0 || p+20 > len(b) {
+		return nil, ErrUnsupported
+	}
+
+	l.grid = make([]int, i)
+	for k := range l.grid {
+		l.grid[k] = int(b[p+k])
+		if l.grid[k] < 2 {
+			return nil, ErrUnsupported
+		}
+	}
+
+	s := int(b[p+16])
+	n := 1
+	for _, g := range l.grid {
+		n *= g
+	}
+	n *= o
+	if (s != 1 && s != 2) || p+20+n*s > len(b) {
+		return nil, ErrUnsupported
+	}
+
+	l.clut = make([]float64, n)
+	for k := range l.clut {
+		if s == 1 {
+			l.clut[k] = float64(b[p+20+k]) / 255
+		} else {
+			l.clut[k] = float64(binary.BigEndian.Uint16(b[p+20+k*2:])) / 65535
+		}
+	}
+
+	return l, nil
+}
+
+func curves(b []byte, p, n int) ([]curve, error) {
+	if p == 0 {
+		return nil, nil
+	}
+
+	c := make([]curve, n)
+	for i := range c {
+		if p > len(b) {
+			return nil, ErrUnsupported
+		}
+
+		var l int
+		var err error
+		c[i], l, err = parseCurve(b[p:])
+		if err != nil {
+			return nil, err
+		}
+		p += (l + 3) &^ 3
+	}
+
+	return c, nil
+}
+
+func labToXYZ(l, a, b float64) [3]float64 {
+	f := func(t float64) float64 {
+		if t > 6.0/29 {
+			return t * t * t
+		}
+		return 3 * 6.0 / 29 * 6.0 / 29 * (t - 4.0/29)
+	}
+
+	y := (l + 16) / 116
+
+	return [3]float64{
+		0.9642 * f(y+a/500),
+		f(y),
+		0.8249 * f(y-b/200),
+	}
+}
+
+func clamp(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
+
+func pow(b, e int) int {
+	r := 1
+	for i := 0; i < e; i++ {
+		r *= b
+	}
+
+	return r
+}
@@ -1,7 +1,10 @@
 package loader
 
 import (
+	"context"
+	"fmt"
 	"github.com/ueef/mosaic/pkg/parse"
+	"github.com/ueef/mosaic/pkg/saver"
 	"io/ioutil"
 	"os"
 	"regexp"
@@ -14,11 +17,27 @@ type Direct struct {
 }
 
 func (s Direct) Load(path string) ([]byte, error) {
+	return s.LoadContext(context.Background(), path)
+}
+
+func (s Direct) LoadContext(ctx context.Context, path string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	if nil != s.p {
 		path = s.p.ReplaceAllString(path, s.r)
 	}
 
-	f, err := os.Open(s.d + path)
+	path, err := saver.Contain(s.d, path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", err, ErrNotFound)
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%s: %w", err, ErrNotFound)
+	}
 	if err != nil {
 		return nil, err
 	}
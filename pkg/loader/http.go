@@ -1,7 +1,9 @@
 package loader
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"github.com/ueef/mosaic/pkg/parse"
 	"io/ioutil"
 	"net/http"
@@ -16,17 +18,30 @@ type Http struct {
 }
 
 func (s Http) Load(path string) ([]byte, error) {
+	return s.LoadContext(context.Background(), path)
+}
+
+func (s Http) LoadContext(ctx context.Context, path string) ([]byte, error) {
 	if s.pattern != nil {
 		path = s.pattern.ReplaceAllString(path, s.replace)
 	}
 
-	r, err := http.Get(s.scheme + "://" + s.host + path)
+	q, err := http.NewRequestWithContext(ctx, http.MethodGet, s.scheme+"://"+s.host+path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := http.DefaultClient.Do(q)
 	if err != nil {
 		return nil, err
 	}
 	defer r.Body.Close()
 
-	if r.StatusCode != 200 {
+	if r.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%s: %w", r.Status, ErrNotFound)
+	}
+
+	if r.StatusCode != http.StatusOK {
 		return nil, errors.New(r.Status)
 	}
 
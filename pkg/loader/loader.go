@@ -1,6 +1,7 @@
 package loader
 
 import (
+	"context"
 	"errors"
 	"github.com/ueef/mosaic/pkg/parse"
 )
@@ -8,10 +9,17 @@ import (
 const TypeHttp = "http"
 const TypeDirect = "direct"
 
+var ErrNotFound = errors.New("a source is not found")
+
 type Loader interface {
 	Load(path string) ([]byte, error)
 }
 
+type ContextLoader interface {
+	Loader
+	LoadContext(ctx context.Context, path string) ([]byte, error)
+}
+
 func New(t string, m map[string]interface{}) (s Loader, err error) {
 	switch t {
 	case TypeHttp:
@@ -39,5 +47,10 @@ func NewFromConfig(c interface{}) (s Loader, err error) {
 		return nil, err
 	}
 
-	return New(t, m)
+	v, err := New(t, m)
+	if err != nil {
+		return nil, parse.Nest(err, "config")
+	}
+
+	return v, nil
 }
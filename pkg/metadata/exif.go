@@ -0,0 +1,132 @@
+package metadata
+
+import (
+	"encoding/binary"
+)
+
+const tagOrientation = 0x0112
+const tagArtist = 0x013b
+const tagCopyright = 0x8298
+
+type ifdEntry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	value []byte
+}
+
+func parseIfd0(b []byte) (binary.ByteOrder, []ifdEntry, bool) {
+	if len(b) < 8 {
+		return nil, nil, false
+	}
+
+	var o binary.ByteOrder
+	switch string(b[:2]) {
+	case "II":
+		o = binary.LittleEndian
+	case "MM":
+		o = binary.BigEndian
+	default:
+		return nil, nil, false
+	}
+
+	p := int(o.Uint32(b[4:8]))
+	if p < 8 || p+2 > len(b) {
+		return nil, nil, false
+	}
+
+	n := int(o.Uint16(b[p:]))
+	p += 2
+	if p+n*12 > len(b) {
+		return nil, nil, false
+	}
+
+	e := make([]ifdEntry, n)
+	for i := range e {
+		s := b[p+i*12 : p+i*12+12]
+		e[i] = ifdEntry{
+			tag:   o.Uint16(s[0:2]),
+			typ:   o.Uint16(s[2:4]),
+			count: o.Uint32(s[4:8]),
+			value: s[8:12],
+		}
+
+		if e[i].typ != 2 || e[i].count <= 4 {
+			continue
+		}
+
+		v := int(o.Uint32(s[8:12]))
+		if v < 0 || v+int(e[i].count) > len(b) {
+			e[i].value = nil
+			continue
+		}
+		e[i].value = b[v : v+int(e[i].count)]
+	}
+
+	return o, e, true
+}
+
+func copyrightExif(b []byte) []byte {
+	_, es, ok := parseIfd0(b)
+	if !ok {
+		return nil
+	}
+
+	var s []ifdEntry
+	for _, e := range es {
+		if (e.tag == tagArtist || e.tag == tagCopyright) && e.typ == 2 && e.value != nil {
+			s = append(s, e)
+		}
+	}
+	if len(s) == 0 {
+		return nil
+	}
+
+	o := binary.LittleEndian
+	d := 8 + 2 + len(s)*12 + 4
+	t := make([]byte, d)
+	copy(t, "II")
+	o.PutUint16(t[2:], 42)
+	o.PutUint32(t[4:], 8)
+	o.PutUint16(t[8:], uint16(len(s)))
+	for i, e := range s {
+		p := t[10+i*12:]
+		o.PutUint16(p[0:], e.tag)
+		o.PutUint16(p[2:], e.typ)
+		o.PutUint32(p[4:], e.count)
+		if e.count <= 4 {
+			copy(p[8:12], e.value[:e.count])
+			continue
+		}
+
+		o.PutUint32(p[8:], uint32(len(t)))
+		t = append(t, e.value...)
+		if len(t)%2 != 0 {
+			t = append(t, 0)
+		}
+	}
+
+	return t
+}
+
+func resetOrientation(b []byte) []byte {
+	o, es, ok := parseIfd0(b)
+	if !ok {
+		return b
+	}
+
+	for i, e := range es {
+		if e.tag != tagOrientation || e.typ != 3 {
+			continue
+		}
+
+		p := int(o.Uint32(b[4:8])) + 2 + i*12 + 8
+		c := make([]byte, len(b))
+		copy(c, b)
+		o.PutUint16(c[p:], 1)
+
+		return c
+	}
+
+	return b
+}
@@ -0,0 +1,103 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"sort"
+)
+
+const jpegMaxSegment = 0xffff - 2
+
+var jpegSignature = []byte{0xff, 0xd8}
+var jpegExifHeader = []byte("Exif\x00\x00")
+var jpegXmpHeader = []byte("http://ns.adobe.com/xap/1.0/\x00")
+var jpegIccHeader = []byte("ICC_PROFILE\x00")
+
+func extractJpeg(b []byte) Metadata {
+	m := Metadata{}
+	icc := map[byte][]byte{}
+
+	p := 2
+	for p+4 <= len(b) && b[p] == 0xff {
+		mk := b[p+1]
+		if mk == 0xd9 || mk == 0xda {
+			break
+		}
+
+		l := int(binary.BigEndian.Uint16(b[p+2:]))
+		if l < 2 || p+2+l > len(b) {
+			break
+		}
+		s := b[p+4 : p+2+l]
+		p += 2 + l
+
+		switch {
+		case mk == 0xe1 && bytes.HasPrefix(s, jpegExifHeader) && m.Exif == nil:
+			m.Exif = s[len(jpegExifHeader):]
+		case mk == 0xe1 && bytes.HasPrefix(s, jpegXmpHeader) && m.Xmp == nil:
+			m.Xmp = s[len(jpegXmpHeader):]
+		case mk == 0xe2 && bytes.HasPrefix(s, jpegIccHeader) && len(s) > len(jpegIccHeader)+2:
+			icc[s[len(jpegIccHeader)]] = s[len(jpegIccHeader)+2:]
+		}
+	}
+
+	if len(icc) > 0 {
+		k := make([]int, 0, len(icc))
+		for i := range icc {
+			k = append(k, int(i))
+		}
+		sort.Ints(k)
+
+		for _, i := range k {
+			m.Icc = append(m.Icc, icc[byte(i)]...)
+		}
+	}
+
+	return m
+}
+
+func EmbedJpeg(b []byte, m Metadata) ([]byte, error) {
+	if !bytes.HasPrefix(b, jpegSignature) {
+		return nil, errors.New("a data isn't a jpeg image")
+	}
+
+	s := bytes.Buffer{}
+	s.Write(jpegSignature)
+
+	if len(m.Exif) > 0 && len(jpegExifHeader)+len(m.Exif) <= jpegMaxSegment {
+		writeJpegSegment(&s, 0xe1, jpegExifHeader, m.Exif)
+	}
+	if len(m.Xmp) > 0 && len(jpegXmpHeader)+len(m.Xmp) <= jpegMaxSegment {
+		writeJpegSegment(&s, 0xe1, jpegXmpHeader, m.Xmp)
+	}
+	if len(m.Icc) > 0 {
+		cl := jpegMaxSegment - len(jpegIccHeader) - 2
+		n := (len(m.Icc) + cl - 1) / cl
+		if n <= 0xff {
+			for i := 0; i < n; i++ {
+				e := (i + 1) * cl
+				if e > len(m.Icc) {
+					e = len(m.Icc)
+				}
+
+				h := append(append([]byte{}, jpegIccHeader...), byte(i+1), byte(n))
+				writeJpegSegment(&s, 0xe2, h, m.Icc[i*cl:e])
+			}
+		}
+	}
+
+	s.Write(b[len(jpegSignature):])
+
+	return s.Bytes(), nil
+}
+
+func writeJpegSegment(w *bytes.Buffer, mk byte, h, d []byte) {
+	l := make([]byte, 2)
+	binary.BigEndian.PutUint16(l, uint16(2+len(h)+len(d)))
+
+	w.Write([]byte{0xff, mk})
+	w.Write(l)
+	w.Write(h)
+	w.Write(d)
+}
@@ -0,0 +1,66 @@
+package metadata
+
+import (
+	"bytes"
+	"errors"
+)
+
+type Policy string
+
+const PolicyStrip Policy = "strip"
+const PolicyCopyright Policy = "copyright"
+const PolicyAll Policy = "all"
+
+type Metadata struct {
+	Exif []byte
+	Xmp  []byte
+	Icc  []byte
+}
+
+func (m Metadata) IsEmpty() bool {
+	return len(m.Exif) == 0 && len(m.Xmp) == 0 && len(m.Icc) == 0
+}
+
+func (m Metadata) Select(p Policy) Metadata {
+	switch p {
+	case PolicyAll:
+		return m
+	case PolicyCopyright:
+		return Metadata{
+			Exif: copyrightExif(m.Exif),
+			Icc:  m.Icc,
+		}
+	}
+
+	return Metadata{}
+}
+
+func (m Metadata) ResetOrientation() Metadata {
+	m.Exif = resetOrientation(m.Exif)
+
+	return m
+}
+
+func Extract(b []byte) Metadata {
+	switch {
+	case bytes.HasPrefix(b, jpegSignature):
+		return extractJpeg(b)
+	case bytes.HasPrefix(b, pngSignature):
+		return extractPng(b)
+	case len(b) >= 12 && string(b[:4]) == "RIFF" && string(b[8:12]) == "WEBP":
+		m := extractWebp(b)
+		m.Exif = bytes.TrimPrefix(m.Exif, jpegExifHeader)
+		return m
+	}
+
+	return Metadata{}
+}
+
+func ParsePolicy(s string) (Policy, error) {
+	switch p := Policy(s); p {
+	case PolicyStrip, PolicyCopyright, PolicyAll:
+		return p, nil
+	}
+
+	return "", errors.New("a metadata policy \"" + s + "\" is undefined")
+}
@@ -0,0 +1,129 @@
+package metadata
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+)
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+var pngXmpKeyword = []byte("XML:com.adobe.xmp")
+
+func extractPng(b []byte) Metadata {
+	m := Metadata{}
+
+	p := len(pngSignature)
+	for p+12 <= len(b) {
+		l := int(binary.BigEndian.Uint32(b[p:]))
+		if l < 0 || p+12+l > len(b) {
+			break
+		}
+		t := string(b[p+4 : p+8])
+		d := b[p+8 : p+8+l]
+		p += 12 + l
+
+		switch t {
+		case "eXIf":
+			m.Exif = d
+		case "iCCP":
+			i := bytes.IndexByte(d, 0)
+			if i < 0 || i+2 > len(d) {
+				continue
+			}
+			m.Icc = inflate(d[i+2:])
+		case "iTXt":
+			if !bytes.HasPrefix(d, pngXmpKeyword) || len(d) < len(pngXmpKeyword)+3 {
+				continue
+			}
+			c := d[len(pngXmpKeyword)+1]
+			d = d[len(pngXmpKeyword)+3:]
+			for k := 0; k < 2; k++ {
+				i := bytes.IndexByte(d, 0)
+				if i < 0 {
+					d = nil
+					break
+				}
+				d = d[i+1:]
+			}
+			if c == 1 {
+				d = inflate(d)
+			}
+			m.Xmp = d
+		case "IDAT", "IEND":
+			return m
+		}
+	}
+
+	return m
+}
+
+func EmbedPng(b []byte, m Metadata) ([]byte, error) {
+	if !bytes.HasPrefix(b, pngSignature) || len(b) < len(pngSignature)+12 {
+		return nil, errors.New("a data isn't a png image")
+	}
+
+	p := len(pngSignature) + 12 + int(binary.BigEndian.Uint32(b[len(pngSignature):]))
+	if p > len(b) {
+		return nil, errors.New("a data isn't a png image")
+	}
+
+	s := bytes.Buffer{}
+	s.Write(b[:p])
+
+	if len(m.Icc) > 0 {
+		z := bytes.Buffer{}
+		w := zlib.NewWriter(&z)
+		_, _ = w.Write(m.Icc)
+		_ = w.Close()
+
+		writePngChunk(&s, "iCCP", append([]byte("ICC profile\x00\x00"), z.Bytes()...))
+	}
+	if len(m.Exif) > 0 {
+		writePngChunk(&s, "eXIf", m.Exif)
+	}
+	if len(m.Xmp) > 0 {
+		d := append(append([]byte{}, pngXmpKeyword...), 0, 0, 0, 0, 0)
+		writePngChunk(&s, "iTXt", append(d, m.Xmp...))
+	}
+
+	s.Write(b[p:])
+
+	return s.Bytes(), nil
+}
+
+func writePngChunk(w *bytes.Buffer, t string, d []byte) {
+	l := make([]byte, 4)
+	binary.BigEndian.PutUint32(l, uint32(len(d)))
+
+	c := crc32.NewIEEE()
+	_, _ = c.Write([]byte(t))
+	_, _ = c.Write(d)
+
+	w.Write(l)
+	w.WriteString(t)
+	w.Write(d)
+	w.Write(c.Sum(nil))
+}
+
+// pngMaxInflated bounds a decompressed chunk, as a few kilobytes of a
+// compressed one could take gigabytes.
+const pngMaxInflated = 4 << 20
+
+func inflate(b []byte) []byte {
+	r, err := zlib.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil
+	}
+	defer r.Close()
+
+	d, err := ioutil.ReadAll(io.LimitReader(r, pngMaxInflated+1))
+	if err != nil || len(d) > pngMaxInflated {
+		return nil
+	}
+
+	return d
+}
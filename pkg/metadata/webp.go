@@ -0,0 +1,31 @@
+package metadata
+
+import (
+	"encoding/binary"
+)
+
+func extractWebp(b []byte) Metadata {
+	m := Metadata{}
+
+	p := 12
+	for p+8 <= len(b) {
+		l := int(binary.LittleEndian.Uint32(b[p+4:]))
+		if l < 0 || p+8+l > len(b) {
+			break
+		}
+		t := string(b[p : p+4])
+		d := b[p+8 : p+8+l]
+		p += 8 + l + l%2
+
+		switch t {
+		case "EXIF":
+			m.Exif = d
+		case "XMP ":
+			m.Xmp = d
+		case "ICCP":
+			m.Icc = d
+		}
+	}
+
+	return m
+}
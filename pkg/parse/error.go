@@ -0,0 +1,131 @@
+package parse
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Error is an error of a config value located by a path of keys and indexes
+// from the config's root, e.g. pictures[3].filter[1].config.width.
+type Error struct {
+	Path []string
+	Err  error
+}
+
+func (e *Error) Key() string {
+	var b strings.Builder
+	for i, p := range e.Path {
+		if i > 0 && !strings.HasPrefix(p, "[") {
+			b.WriteByte('.')
+		}
+		b.WriteString(p)
+	}
+
+	return b.String()
+}
+
+func (e *Error) Error() string {
+	return e.Key() + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Errors is a list of errors of a config, for constructors which go on
+// after an invalid value to report every one.
+type Errors []error
+
+func (e Errors) Error() string {
+	s := make([]string, len(e))
+	for i := range e {
+		s[i] = e[i].Error()
+	}
+
+	return strings.Join(s, "; ")
+}
+
+// Add appends err, if it isn't nil, with a path prepended, telling if it did.
+func (e *Errors) Add(err error, path ...string) bool {
+	if err == nil {
+		return false
+	}
+	if len(path) > 0 {
+		err = Prefix(err, path...)
+	}
+
+	if es, ok := err.(Errors); ok {
+		*e = append(*e, es...)
+	} else {
+		*e = append(*e, err)
+	}
+
+	return true
+}
+
+// Err returns nil for no errors, the only error for one, and e for more.
+func (e Errors) Err() error {
+	switch len(e) {
+	case 0:
+		return nil
+	case 1:
+		return e[0]
+	}
+
+	return e
+}
+
+// Prefix prepends a path to the one of err, making it an *Error if it isn't.
+func Prefix(err error, path ...string) error {
+	if err == nil {
+		return nil
+	}
+
+	if es, ok := err.(Errors); ok {
+		r := make(Errors, len(es))
+		for i := range es {
+			r[i] = Prefix(es[i], path...)
+		}
+		return r
+	}
+
+	var e *Error
+	if errors.As(err, &e) {
+		return &Error{Path: append(append([]string{}, path...), e.Path...), Err: e.Err}
+	}
+
+	return &Error{Path: path, Err: err}
+}
+
+func Index(i int) string {
+	return "[" + strconv.Itoa(i) + "]"
+}
+
+func errorf(k string, format string, a ...interface{}) error {
+	return &Error{Path: []string{k}, Err: fmt.Errorf(format, a...)}
+}
+
+func undefined(k string) error {
+	return errorf(k, "a key is undefined")
+}
+
+// Nest prepends a path to the one of err if err is an *Error, leaving other
+// errors, which aren't bound to a key, as they are.
+func Nest(err error, path ...string) error {
+	if es, ok := err.(Errors); ok {
+		r := make(Errors, len(es))
+		for i := range es {
+			r[i] = Nest(es[i], path...)
+		}
+		return r
+	}
+
+	var e *Error
+	if !errors.As(err, &e) {
+		return err
+	}
+
+	return Prefix(err, path...)
+}
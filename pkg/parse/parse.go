@@ -1,6 +1,7 @@
 package parse
 
 import (
+	"errors"
 	"fmt"
 	"github.com/anthonynsimon/bild/clone"
 	"github.com/golang/freetype"
@@ -13,12 +14,14 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
 var fonts = map[string]*truetype.Font{}
 var images = map[string]*image.RGBA{}
 
 func GetMapFromMap(k string, m map[string]interface{}) (map[string]interface{}, bool, error) {
+	use(m, k)
 	o, ok := m[k]
 	if !ok {
 		return nil, false, nil
@@ -26,8 +29,9 @@ func GetMapFromMap(k string, m map[string]interface{}) (map[string]interface{},
 
 	v, ok := o.(map[string]interface{})
 	if !ok {
-		return nil, true, fmt.Errorf("a value of a key \"%s\" must be a map[string]interface{}, got %T in map %v", k, o, m)
+		return nil, true, errorf(k, "a value must be a map[string]interface{}, got %T", o)
 	}
+	use(v, "")
 
 	return v, true, nil
 }
@@ -35,13 +39,14 @@ func GetMapFromMap(k string, m map[string]interface{}) (map[string]interface{},
 func GetRequiredMapFromMap(k string, m map[string]interface{}) (map[string]interface{}, error) {
 	v, ok, err := GetMapFromMap(k, m)
 	if !ok {
-		return nil, fmt.Errorf("a key \"%s\" is undefined in map %v", k, m)
+		return nil, undefined(k)
 	}
 
 	return v, err
 }
 
 func GetIntFromMap(k string, m map[string]interface{}) (int, bool, error) {
+	use(m, k)
 	o, ok := m[k]
 	if !ok {
 		return 0, false, nil
@@ -54,19 +59,20 @@ func GetIntFromMap(k string, m map[string]interface{}) (int, bool, error) {
 		return int(v), true, nil
 	}
 
-	return 0, true, fmt.Errorf("a value of a key \"%s\" must be an integer, got %T in map %v", k, o, m)
+	return 0, true, errorf(k, "a value must be an integer, got %T", o)
 }
 
 func GetRequiredIntFromMap(k string, m map[string]interface{}) (int, error) {
 	v, ok, err := GetIntFromMap(k, m)
 	if !ok {
-		return 0, fmt.Errorf("a key \"%s\" is undefined in map %v", k, m)
+		return 0, undefined(k)
 	}
 
 	return v, err
 }
 
 func GetFloatFromMap(k string, m map[string]interface{}) (float64, bool, error) {
+	use(m, k)
 	o, ok := m[k]
 	if !ok {
 		return 0, false, nil
@@ -79,19 +85,44 @@ func GetFloatFromMap(k string, m map[string]interface{}) (float64, bool, error)
 		return v, true, nil
 	}
 
-	return 0, true, fmt.Errorf("a value of a key \"%s\" must be an float, got %T in map %v", k, o, m)
+	return 0, true, errorf(k, "a value must be a float, got %T", o)
 }
 
 func GetRequiredFloatFromMap(k string, m map[string]interface{}) (float64, error) {
 	v, ok, err := GetFloatFromMap(k, m)
 	if !ok {
-		return 0, fmt.Errorf("a key \"%s\" is undefined in map %v", k, m)
+		return 0, undefined(k)
+	}
+
+	return v, err
+}
+
+func GetBoolFromMap(k string, m map[string]interface{}) (bool, bool, error) {
+	use(m, k)
+	o, ok := m[k]
+	if !ok {
+		return false, false, nil
+	}
+
+	v, ok := o.(bool)
+	if !ok {
+		return false, true, errorf(k, "a value must be a boolean, got %T", o)
+	}
+
+	return v, true, nil
+}
+
+func GetRequiredBoolFromMap(k string, m map[string]interface{}) (bool, error) {
+	v, ok, err := GetBoolFromMap(k, m)
+	if !ok {
+		return false, undefined(k)
 	}
 
 	return v, err
 }
 
 func GetStringFromMap(k string, m map[string]interface{}) (string, bool, error) {
+	use(m, k)
 	o, ok := m[k]
 	if !ok {
 		return "", false, nil
@@ -99,7 +130,7 @@ func GetStringFromMap(k string, m map[string]interface{}) (string, bool, error)
 
 	v, ok := o.(string)
 	if !ok {
-		return "", true, fmt.Errorf("a value of a key \"%s\" must be a string, got %T in map %v", k, o, m)
+		return "", true, errorf(k, "a value must be a string, got %T", o)
 	}
 
 	return v, true, nil
@@ -108,7 +139,7 @@ func GetStringFromMap(k string, m map[string]interface{}) (string, bool, error)
 func GetRequiredStringFromMap(k string, m map[string]interface{}) (string, error) {
 	v, ok, err := GetStringFromMap(k, m)
 	if !ok {
-		return "", fmt.Errorf("a key \"%s\" is undefined in map %v", k, m)
+		return "", undefined(k)
 	}
 
 	return v, err
@@ -120,14 +151,81 @@ func GetColorFromMap(k string, m map[string]interface{}) (color.Color, bool, err
 		return nil, false, err
 	}
 
-	ok, err = regexp.MatchString("#[abcdef\\d]{4}", v)
+	c, err := parseColor(v)
 	if err != nil {
-		return nil, true, err
+		return nil, true, Prefix(err, k)
+	}
+
+	return c, true, nil
+}
+
+func GetRequiredColorFromMap(k string, m map[string]interface{}) (color.Color, error) {
+	v, ok, err := GetColorFromMap(k, m)
+	if !ok {
+		return nil, undefined(k)
+	}
+
+	return v, err
+}
+
+func GetStringsFromMap(k string, m map[string]interface{}) ([]string, bool, error) {
+	sv, ok, err := GetSliceOfInterfacesFromMap(k, m)
+	if !ok || err != nil {
+		return nil, ok, err
+	}
+
+	s := make([]string, len(sv))
+	for i := range sv {
+		v, ok := sv[i].(string)
+		if !ok {
+			return nil, true, &Error{Path: []string{k, Index(i)}, Err: fmt.Errorf("a value must be a string, got %T", sv[i])}
+		}
+		s[i] = v
+	}
+
+	return s, true, nil
+}
+
+func GetColorsFromMap(k string, m map[string]interface{}) ([]color.Color, bool, error) {
+	sv, ok, err := GetSliceOfInterfacesFromMap(k, m)
+	if !ok || err != nil {
+		return nil, ok, err
+	}
+
+	c := make([]color.Color, len(sv))
+	for i := range sv {
+		v, ok := sv[i].(string)
+		if !ok {
+			return nil, true, &Error{Path: []string{k, Index(i)}, Err: fmt.Errorf("a value must be a string, got %T", sv[i])}
+		}
+
+		c[i], err = parseColor(v)
+		if err != nil {
+			return nil, true, Prefix(err, k, Index(i))
+		}
+	}
+
+	return c, true, nil
+}
+
+func GetRequiredColorsFromMap(k string, m map[string]interface{}) ([]color.Color, error) {
+	v, ok, err := GetColorsFromMap(k, m)
+	if !ok {
+		return nil, undefined(k)
+	}
+
+	return v, err
+}
+
+func parseColor(v string) (color.Color, error) {
+	ok, err := regexp.MatchString("^#[abcdef\\d]{4}$", v)
+	if err != nil {
+		return nil, err
 	}
 	if ok {
 		cv, err := strconv.ParseUint(v[1:], 16, 32)
 		if err != nil {
-			return nil, true, err
+			return nil, err
 		}
 
 		c := color.RGBA{
@@ -137,12 +235,12 @@ func GetColorFromMap(k string, m map[string]interface{}) (color.Color, bool, err
 			A: uint8((15 & cv) * 17),
 		}
 
-		return &c, true, nil
+		return &c, nil
 	}
 
-	ok, err = regexp.MatchString("#[abcdef\\d]{8}", v)
+	ok, err = regexp.MatchString("^#[abcdef\\d]{8}$", v)
 	if err != nil {
-		return nil, true, err
+		return nil, err
 	}
 	if ok {
 		cv, err := strconv.ParseUint(v[1:], 16, 32)
@@ -157,19 +255,19 @@ func GetColorFromMap(k string, m map[string]interface{}) (color.Color, bool, err
 			A: uint8(255 & cv),
 		}
 
-		return &c, true, nil
+		return &c, nil
 	}
 
-	ok, err = regexp.MatchString("rgba\\(\\d{1,3},\\d{1,3},\\d{1,3},\\d{1,3}\\)", v)
+	ok, err = regexp.MatchString("^rgba\\(\\d{1,3},\\d{1,3},\\d{1,3},\\d{1,3}\\)$", v)
 	if err != nil {
-		return nil, true, err
+		return nil, err
 	}
 	if ok {
 		cv := [4]uint8{0, 0, 0, 0}
 		for i, s := range strings.Split(v[5:len(v)-1], ",") {
 			v, err := strconv.ParseUint(s, 10, 8)
 			if err != nil {
-				return nil, true, err
+				return nil, err
 			}
 			cv[i] = uint8(v)
 		}
@@ -181,19 +279,10 @@ func GetColorFromMap(k string, m map[string]interface{}) (color.Color, bool, err
 			A: cv[3],
 		}
 
-		return &c, true, nil
-	}
-
-	return nil, true, fmt.Errorf("a value of a key \"%s\" have unsupported format. expected format is #ffff, #ffffffff or rgba(255,255,255,255)", k)
-}
-
-func GetRequiredColorFromMap(k string, m map[string]interface{}) (color.Color, error) {
-	v, ok, err := GetColorFromMap(k, m)
-	if !ok {
-		return nil, fmt.Errorf("a key \"%s\" is undefined in map %v", k, m)
+		return &c, nil
 	}
 
-	return v, err
+	return nil, errors.New("a value has an unsupported format, expected #ffff, #ffffffff or rgba(255,255,255,255)")
 }
 
 func GetFontFromMap(k string, m map[string]interface{}) (*truetype.Font, bool, error) {
@@ -209,12 +298,12 @@ func GetFontFromMap(k string, m map[string]interface{}) (*truetype.Font, bool, e
 
 	b, err := ioutil.ReadFile(v)
 	if err != nil {
-		return nil, true, err
+		return nil, true, Prefix(err, k)
 	}
 
 	f, err = freetype.ParseFont(b)
 	if err != nil {
-		return nil, true, err
+		return nil, true, Prefix(err, k)
 	}
 
 	fonts[v] = f
@@ -225,7 +314,7 @@ func GetFontFromMap(k string, m map[string]interface{}) (*truetype.Font, bool, e
 func GetRequiredFontFromMap(k string, m map[string]interface{}) (*truetype.Font, error) {
 	v, ok, err := GetFontFromMap(k, m)
 	if !ok {
-		return nil, fmt.Errorf("a key \"%s\" is undefined in map %v", k, m)
+		return nil, undefined(k)
 	}
 
 	return v, err
@@ -244,12 +333,12 @@ func GetImageFromMap(k string, m map[string]interface{}) (*image.RGBA, bool, err
 
 	f, err := os.Open(v)
 	if err != nil {
-		return nil, true, err
+		return nil, true, Prefix(err, k)
 	}
 
 	d, _, err := image.Decode(f)
 	if err != nil {
-		return nil, true, err
+		return nil, true, Prefix(err, k)
 	}
 
 	i, ok = d.(*image.RGBA)
@@ -265,7 +354,7 @@ func GetImageFromMap(k string, m map[string]interface{}) (*image.RGBA, bool, err
 func GetRequiredImageFromMap(k string, m map[string]interface{}) (*image.RGBA, error) {
 	v, ok, err := GetImageFromMap(k, m)
 	if !ok {
-		return nil, fmt.Errorf("a key \"%s\" is undefined in map %v", k, m)
+		return nil, undefined(k)
 	}
 
 	return v, err
@@ -286,13 +375,46 @@ func GetFontHintingFromMap(k string, m map[string]interface{}) (font.Hinting, bo
 		return font.HintingVertical, true, nil
 	}
 
-	return font.HintingNone, true, fmt.Errorf("a value of a key \"%s\" is invalid. expected none, full or vertical, got %v", k, v)
+	return font.HintingNone, true, errorf(k, "a value is invalid, expected none, full or vertical, got %v", v)
 }
 
 func GetRequiredFontHintingFromMap(k string, m map[string]interface{}) (font.Hinting, error) {
 	v, ok, err := GetFontHintingFromMap(k, m)
 	if !ok {
-		return font.HintingNone, fmt.Errorf("a key \"%s\" is undefined in map %v", k, m)
+		return font.HintingNone, undefined(k)
+	}
+
+	return v, err
+}
+
+func GetDurationFromMap(k string, m map[string]interface{}) (time.Duration, bool, error) {
+	use(m, k)
+	o, ok := m[k]
+	if !ok {
+		return 0, false, nil
+	}
+
+	switch v := o.(type) {
+	case int:
+		return time.Duration(v) * time.Second, true, nil
+	case float64:
+		return time.Duration(v * float64(time.Second)), true, nil
+	case string:
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return 0, true, errorf(k, "a value is invalid: %w", err)
+		}
+
+		return d, true, nil
+	}
+
+	return 0, true, errorf(k, "a value must be a duration or a number of seconds, got %T", o)
+}
+
+func GetRequiredDurationFromMap(k string, m map[string]interface{}) (time.Duration, error) {
+	v, ok, err := GetDurationFromMap(k, m)
+	if !ok {
+		return 0, undefined(k)
 	}
 
 	return v, err
@@ -306,7 +428,7 @@ func GetRegexpFromMap(k string, m map[string]interface{}) (*regexp.Regexp, bool,
 
 	r, err := regexp.Compile(v)
 	if err != nil {
-		return nil, true, err
+		return nil, true, Prefix(err, k)
 	}
 
 	return r, true, nil
@@ -315,13 +437,14 @@ func GetRegexpFromMap(k string, m map[string]interface{}) (*regexp.Regexp, bool,
 func GetRequiredRegexpFromMap(k string, m map[string]interface{}) (*regexp.Regexp, error) {
 	v, ok, err := GetRegexpFromMap(k, m)
 	if !ok {
-		return nil, fmt.Errorf("a key \"%s\" is undefined in map %v", k, m)
+		return nil, undefined(k)
 	}
 
 	return v, err
 }
 
 func GetInterfaceFromMap(k string, m map[string]interface{}) (interface{}, bool, error) {
+	use(m, k)
 	o, ok := m[k]
 	if !ok {
 		return nil, false, nil
@@ -333,13 +456,14 @@ func GetInterfaceFromMap(k string, m map[string]interface{}) (interface{}, bool,
 func GetRequiredInterfaceFromMap(k string, m map[string]interface{}) (interface{}, error) {
 	v, ok, err := GetInterfaceFromMap(k, m)
 	if !ok {
-		return nil, fmt.Errorf("a key \"%s\" is undefined in map %v", k, m)
+		return nil, undefined(k)
 	}
 
 	return v, err
 }
 
 func GetSliceOfInterfacesFromMap(k string, m map[string]interface{}) ([]interface{}, bool, error) {
+	use(m, k)
 	o, ok := m[k]
 	if !ok {
 		return nil, false, nil
@@ -347,7 +471,7 @@ func GetSliceOfInterfacesFromMap(k string, m map[string]interface{}) ([]interfac
 
 	v, ok := o.([]interface{})
 	if !ok {
-		return nil, true, fmt.Errorf("a value of a key \"%s\" must be a slice of interfaces, got %T in map %v", k, o, m)
+		return nil, true, errorf(k, "a value must be a slice of interfaces, got %T", o)
 	}
 
 	return v, true, nil
@@ -356,7 +480,7 @@ func GetSliceOfInterfacesFromMap(k string, m map[string]interface{}) ([]interfac
 func GetRequiredSliceOfInterfacesFromMap(k string, m map[string]interface{}) ([]interface{}, error) {
 	v, ok, err := GetSliceOfInterfacesFromMap(k, m)
 	if !ok {
-		return nil, fmt.Errorf("a key \"%s\" is undefined in map %v", k, m)
+		return nil, undefined(k)
 	}
 
 	return v, err
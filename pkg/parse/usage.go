@@ -0,0 +1,179 @@
+package parse
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// tracking.on mirrors tracking.u != nil, so the Get functions don't lock
+// while nothing is tracked.
+var tracking struct {
+	sync.Mutex
+	on int32
+	u  *Usage
+}
+
+// Usage records the keys looked up in every config map by the Get functions
+// between Track and Stop, so keys nobody looked up can be reported.
+type Usage struct {
+	keys    map[uintptr]map[string]bool
+	aliases map[uintptr]uintptr
+	copies  []map[string]interface{}
+}
+
+func Track() *Usage {
+	u := &Usage{keys: map[uintptr]map[string]bool{}, aliases: map[uintptr]uintptr{}}
+
+	tracking.Lock()
+	tracking.u = u
+	atomic.StoreInt32(&tracking.on, 1)
+	tracking.Unlock()
+
+	return u
+}
+
+func (u *Usage) Stop() {
+	tracking.Lock()
+	if tracking.u == u {
+		tracking.u = nil
+		atomic.StoreInt32(&tracking.on, 0)
+	}
+	tracking.Unlock()
+}
+
+// Check returns an *Error for every key of a map of c that was read while
+// tracked but wasn't looked up itself, suggesting a looked up key alike.
+func (u *Usage) Check(c interface{}) []error {
+	return u.check(c, nil)
+}
+
+func (u *Usage) check(c interface{}, path []string) []error {
+	var errs []error
+	switch v := c.(type) {
+	case map[string]interface{}:
+		ks := make([]string, 0, len(v))
+		for k := range v {
+			ks = append(ks, k)
+		}
+		sort.Strings(ks)
+
+		used, ok := u.keys[reflect.ValueOf(v).Pointer()]
+		for _, k := range ks {
+			p := append(path[:len(path):len(path)], k)
+			if ok && !used[k] {
+				errs = append(errs, &Error{Path: p, Err: unknown(k, v, used)})
+			}
+			errs = append(errs, u.check(v[k], p)...)
+		}
+	case []interface{}:
+		for i := range v {
+			errs = append(errs, u.check(v[i], append(path[:len(path):len(path)], Index(i)))...)
+		}
+	}
+
+	return errs
+}
+
+func unknown(k string, m map[string]interface{}, used map[string]bool) error {
+	var s string
+	d := 3
+	for c := range used {
+		if _, ok := m[c]; ok {
+			continue
+		}
+		if n := distance(k, c); n < d || (n == d && c < s) {
+			s, d = c, n
+		}
+	}
+
+	if s == "" || d >= len(k) {
+		return fmt.Errorf("a key is unknown")
+	}
+
+	return fmt.Errorf("a key is unknown, did you mean \"%s\"?", s)
+}
+
+func distance(a, b string) int {
+	p := make([]int, len(b)+1)
+	for j := range p {
+		p[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		c := make([]int, len(b)+1)
+		c[0] = i
+		for j := 1; j <= len(b); j++ {
+			n := p[j-1]
+			if a[i-1] != b[j-1] {
+				n++
+			}
+			c[j] = min(n, p[j]+1, c[j-1]+1)
+		}
+		p = c
+	}
+
+	return p[len(b)]
+}
+
+func min(v ...int) int {
+	m := v[0]
+	for _, i := range v[1:] {
+		if i < m {
+			m = i
+		}
+	}
+
+	return m
+}
+
+// Alias makes the keys looked up in dst count as looked up in src, for
+// configs built from a copy of a tracked one.
+func Alias(dst, src map[string]interface{}) {
+	if atomic.LoadInt32(&tracking.on) == 0 {
+		return
+	}
+
+	tracking.Lock()
+	defer tracking.Unlock()
+
+	u := tracking.u
+	if u == nil || dst == nil || src == nil {
+		return
+	}
+
+	p := reflect.ValueOf(src).Pointer()
+	if a, ok := u.aliases[p]; ok {
+		p = a
+	}
+	u.aliases[reflect.ValueOf(dst).Pointer()] = p
+	// a copy is kept so that its address isn't reused while tracked
+	u.copies = append(u.copies, dst)
+}
+
+func use(m map[string]interface{}, k string) {
+	if atomic.LoadInt32(&tracking.on) == 0 {
+		return
+	}
+
+	tracking.Lock()
+	defer tracking.Unlock()
+
+	u := tracking.u
+	if u == nil || m == nil {
+		return
+	}
+
+	p := reflect.ValueOf(m).Pointer()
+	if a, ok := u.aliases[p]; ok {
+		p = a
+	}
+	if u.keys[p] == nil {
+		u.keys[p] = map[string]bool{}
+	}
+	if k != "" {
+		u.keys[p][k] = true
+	}
+}
@@ -0,0 +1,107 @@
+package picture
+
+import (
+	"github.com/ueef/mosaic/pkg/encoder"
+	"strconv"
+	"strings"
+)
+
+func (p Picture) Negotiate(accept string) encoder.Encoder {
+	if len(p.Encoders) == 1 || strings.TrimSpace(accept) == "" {
+		return p.Encoders[0]
+	}
+
+	// of equal qualities, a type named outright wins over a wildcard
+	r := parseAccept(accept)
+	e, q, sp := p.Encoders[0], 0.0, -1
+	for i := range p.Encoders {
+		v, vsp := r.quality(p.Encoders[i].GetMime())
+		if v > q || (v == q && v > 0 && vsp > sp) {
+			e, q, sp = p.Encoders[i], v, vsp
+		}
+	}
+
+	return e
+}
+
+func (p Picture) Key(host, path string, e encoder.Encoder) string {
+	if p.hosted {
+		path = "/" + host + path
+	}
+	if len(p.Encoders) > 1 {
+		path += "." + strings.TrimPrefix(e.GetMime(), "image/")
+	}
+	if q := queryKey(p.o, e); q != "" {
+		path += "@" + q
+	}
+
+	return path
+}
+
+type mediaRange struct {
+	t string
+	s string
+	q float64
+}
+
+type mediaRanges []mediaRange
+
+// quality returns the quality of the most specific range matching mime, and
+// its specificity: 2 for the type itself, 1 for type/* and 0 for */*.
+func (r mediaRanges) quality(mime string) (float64, int) {
+	t, s := mime, ""
+	if i := strings.IndexByte(mime, '/'); i >= 0 {
+		t, s = mime[:i], mime[i+1:]
+	}
+
+	q, sp := 0.0, -1
+	for _, m := range r {
+		v := -1
+		switch {
+		case m.t == t && m.s == s:
+			v = 2
+		case m.t == t && m.s == "*":
+			v = 1
+		case m.t == "*" && m.s == "*":
+			v = 0
+		}
+
+		if v > sp {
+			q, sp = m.q, v
+		}
+	}
+
+	return q, sp
+}
+
+func parseAccept(accept string) mediaRanges {
+	var r mediaRanges
+	for _, v := range strings.Split(accept, ",") {
+		ps := strings.Split(v, ";")
+
+		m := strings.ToLower(strings.TrimSpace(ps[0]))
+		i := strings.IndexByte(m, '/')
+		if i < 0 {
+			continue
+		}
+
+		mr := mediaRange{
+			t: m[:i],
+			s: m[i+1:],
+			q: 1,
+		}
+		for _, p := range ps[1:] {
+			p = strings.TrimSpace(p)
+			if !strings.HasPrefix(p, "q=") {
+				continue
+			}
+			if q, err := strconv.ParseFloat(p[2:], 64); err == nil {
+				mr.q = q
+			}
+		}
+
+		r = append(r, mr)
+	}
+
+	return r
+}
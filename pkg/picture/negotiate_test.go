@@ -0,0 +1,81 @@
+package picture
+
+import (
+	"github.com/ueef/mosaic/pkg/encoder"
+	"image"
+	"testing"
+)
+
+type mime string
+
+func (m mime) Encode(img image.Image) ([]byte, error) {
+	return nil, nil
+}
+
+func (m mime) GetMime() string {
+	return string(m)
+}
+
+const (
+	chrome  = "image/avif,image/webp,image/apng,image/svg+xml,image/*,*/*;q=0.8"
+	firefox = "image/avif,image/webp,*/*"
+	safari  = "image/webp,image/avif,image/jxl,image/heic,image/heic-sequence,video/*;q=0.8,image/png,image/svg+xml,image/*;q=0.8,*/*;q=0.5"
+	legacy  = "image/png,image/svg+xml,image/*;q=0.8,video/*;q=0.8,*/*;q=0.5"
+	curl    = "*/*"
+)
+
+func TestNegotiate(t *testing.T) {
+	for _, tc := range []struct {
+		encoders []string
+		accept   string
+		want     string
+	}{
+		{[]string{"image/jpeg", "image/webp"}, chrome, "image/webp"},
+		{[]string{"image/webp", "image/jpeg"}, chrome, "image/webp"},
+		{[]string{"image/jpeg", "image/webp"}, firefox, "image/webp"},
+		{[]string{"image/jpeg", "image/webp"}, safari, "image/webp"},
+		{[]string{"image/jpeg", "image/png"}, safari, "image/png"},
+		{[]string{"image/jpeg", "image/webp"}, legacy, "image/jpeg"},
+		{[]string{"image/webp", "image/png"}, legacy, "image/png"},
+		{[]string{"image/jpeg", "image/webp"}, curl, "image/jpeg"},
+		{[]string{"image/jpeg", "image/webp"}, "", "image/jpeg"},
+		{[]string{"image/jpeg", "image/webp"}, "image/*,image/jpeg;q=0", "image/webp"},
+		{[]string{"image/jpeg", "image/webp"}, "image/webp;q=0.5,image/*;q=0.6", "image/jpeg"},
+		{[]string{"image/jpeg", "image/webp"}, "text/html", "image/jpeg"},
+		{[]string{"image/jpeg", "image/webp"}, "IMAGE/WEBP", "image/webp"},
+	} {
+		e := make([]encoder.Encoder, len(tc.encoders))
+		for i := range tc.encoders {
+			e[i] = mime(tc.encoders[i])
+		}
+
+		p := Picture{Encoders: e}
+		if got := p.Negotiate(tc.accept).GetMime(); got != tc.want {
+			t.Errorf("Negotiate(%q) with %v = %s, want %s", tc.accept, tc.encoders, got, tc.want)
+		}
+	}
+}
+
+func TestParseAccept(t *testing.T) {
+	for _, tc := range []struct {
+		accept string
+		want   mediaRanges
+	}{
+		{"", nil},
+		{"image/webp", mediaRanges{{"image", "webp", 1}}},
+		{" image/webp ; q=0.5 , */*;level=1;q=0.1", mediaRanges{{"image", "webp", 0.5}, {"*", "*", 0.1}}},
+		{"image/png;q=x,webp", mediaRanges{{"image", "png", 1}}},
+	} {
+		got := parseAccept(tc.accept)
+		if len(got) != len(tc.want) {
+			t.Errorf("parseAccept(%q) = %v, want %v", tc.accept, got, tc.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Errorf("parseAccept(%q) = %v, want %v", tc.accept, got, tc.want)
+				break
+			}
+		}
+	}
+}
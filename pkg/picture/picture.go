@@ -1,28 +1,86 @@
 package picture
 
 import (
+	"crypto/sha1"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"github.com/ueef/mosaic/pkg/encoder"
 	"github.com/ueef/mosaic/pkg/filter"
+	"github.com/ueef/mosaic/pkg/icc"
 	"github.com/ueef/mosaic/pkg/loader"
+	"github.com/ueef/mosaic/pkg/metadata"
 	"github.com/ueef/mosaic/pkg/parse"
 	"github.com/ueef/mosaic/pkg/saver"
+	"github.com/ueef/mosaic/pkg/signature"
 	"regexp"
+	"time"
 )
 
+const AllFrames = -1
+
+var ErrNotMatched = errors.New("there aren't any matching pictures")
+
 type Picture struct {
 	Saver       saver.Saver
 	Loader      loader.Loader
 	Filters     []filter.Filter
-	Encoder     encoder.Encoder
+	Encoders    []encoder.Encoder
 	HostPattern *regexp.Regexp
 	PathPattern *regexp.Regexp
+	Timeout     time.Duration
+	CacheTTL    time.Duration
+	Frame       int
+	AutoOrient  bool
+	Metadata    metadata.Policy
+	Icc         icc.Mode
+	Sidecar     string
+	Signer      *signature.Signer
+	Constraints map[string]Constraint
+	Query       *Query
+	Version     string
+	t           *template
+	o           map[string]string
+	hosted      bool
 }
 
 func (p Picture) Match(host, path string) bool {
+	if p.Signer != nil {
+		path = p.Signer.Strip(path)
+	}
+
 	return (p.HostPattern == nil || p.HostPattern.MatchString(host)) && (p.PathPattern == nil || p.PathPattern.MatchString(path))
 }
 
+func (p Picture) Params(host, path string) filter.Params {
+	var r filter.Params
+	for _, v := range []struct {
+		p *regexp.Regexp
+		s string
+	}{{p.HostPattern, host}, {p.PathPattern, path}} {
+		if v.p == nil {
+			continue
+		}
+
+		m := v.p.FindStringSubmatch(v.s)
+		if m == nil {
+			continue
+		}
+
+		for i, n := range v.p.SubexpNames() {
+			if n == "" || m[i] == "" {
+				continue
+			}
+			if r == nil {
+				r = filter.Params{}
+			}
+			r[n] = m[i]
+		}
+	}
+
+	return r
+}
+
 type Pictures []*Picture
 
 func (p Pictures) Match(host, path string) (*Picture, error) {
@@ -32,17 +90,21 @@ func (p Pictures) Match(host, path string) (*Picture, error) {
 		}
 	}
 
-	return nil, errors.New("there aren't any matching pictures")
+	return nil, ErrNotMatched
 }
 
-func New(saver saver.Saver, loader loader.Loader, filters []filter.Filter, encoder encoder.Encoder, hostPattern *regexp.Regexp, pathPattern *regexp.Regexp) *Picture {
+func New(saver saver.Saver, loader loader.Loader, filters []filter.Filter, encoders []encoder.Encoder, hostPattern *regexp.Regexp, pathPattern *regexp.Regexp) *Picture {
 	return &Picture{
 		Saver:       saver,
 		Loader:      loader,
 		Filters:     filters,
-		Encoder:     encoder,
+		Encoders:    encoders,
 		HostPattern: hostPattern,
 		PathPattern: pathPattern,
+		Frame:       AllFrames,
+		AutoOrient:  true,
+		Metadata:    metadata.PolicyStrip,
+		Icc:         icc.ModeConvert,
 	}
 }
 
@@ -52,60 +114,216 @@ func NewPictureFromConfig(c interface{}) (*Picture, error) {
 		return nil, errors.New("a config must be of the type map[string]interface{}")
 	}
 
+	var errs parse.Errors
+
+	var s saver.Saver
 	iv, err := parse.GetRequiredInterfaceFromMap("saver", mv)
-	if err != nil {
-		return nil, err
-	}
-	s, err := saver.NewFromConfig(iv)
-	if err != nil {
-		return nil, err
+	if !errs.Add(err) {
+		s, err = saver.NewFromConfig(iv)
+		errs.Add(err, "saver")
 	}
 
+	n := len(errs)
+	h, _, err := parse.GetRegexpFromMap("host_pattern", mv)
+	errs.Add(err)
+	p, _, err := parse.GetRegexpFromMap("path_pattern", mv)
+	errs.Add(err)
+	// templates are checked only against valid patterns and params
+	tok := len(errs) == n
+
+	tm := &template{}
+
+	var l loader.Loader
 	iv, err = parse.GetRequiredInterfaceFromMap("loader", mv)
-	if err != nil {
-		return nil, err
-	}
-	l, err := loader.NewFromConfig(iv)
-	if err != nil {
-		return nil, err
+	if !errs.Add(err) {
+		if tm.add("loader", iv) {
+			tm.loader = iv
+		} else {
+			l, err = newLoader(iv)
+			errs.Add(err, "loader")
+		}
 	}
 
+	var f []filter.Filter
 	iv, err = parse.GetRequiredInterfaceFromMap("filter", mv)
-	if err != nil {
-		return nil, err
+	if !errs.Add(err) {
+		if tm.add("filter", iv) {
+			tm.filter = iv
+		} else {
+			f, err = newFilters(iv)
+			errs.Add(err, "filter")
+		}
+	}
+
+	var e []encoder.Encoder
+	iv, err = parse.GetRequiredInterfaceFromMap("encoder", mv)
+	if !errs.Add(err) {
+		if tm.add("encoder", iv) {
+			tm.encoder = iv
+		} else {
+			e, err = newEncoders(iv)
+			errs.Add(err, "encoder")
+		}
+	}
+
+	var cs map[string]Constraint
+	cm, _, err := parse.GetMapFromMap("params", mv)
+	if !errs.Add(err) {
+		cs, err = newConstraints(cm)
+		tok = !errs.Add(err, "params") && tok
+	} else {
+		tok = false
+	}
+
+	t, _, err := parse.GetDurationFromMap("timeout", mv)
+	errs.Add(err)
+
+	ttl, _, err := parse.GetDurationFromMap("cache_ttl", mv)
+	errs.Add(err)
+
+	fr, fok, err := parse.GetIntFromMap("frame", mv)
+	errs.Add(err)
+	if fok && fr < 0 {
+		errs.Add(errors.New("a value must not be negative"), "frame")
+	}
+
+	ao, aok, err := parse.GetBoolFromMap("auto_orient", mv)
+	errs.Add(err)
+
+	mp, mok, err := parse.GetStringFromMap("metadata", mv)
+	errs.Add(err)
+	var mpol metadata.Policy
+	if mok {
+		mpol, err = metadata.ParsePolicy(mp)
+		errs.Add(err, "metadata")
+	}
+
+	im, iok, err := parse.GetStringFromMap("icc", mv)
+	errs.Add(err)
+	var imode icc.Mode
+	if iok {
+		imode, err = icc.ParseMode(im)
+		errs.Add(err, "icc")
+	}
+
+	sc, _, err := parse.GetStringFromMap("sidecar", mv)
+	errs.Add(err)
+
+	var qr *Query
+	qm, ok, err := parse.GetMapFromMap("query", mv)
+	if !errs.Add(err) && ok {
+		qr, err = NewQueryFromMap(qm)
+		errs.Add(err, "query")
 	}
 
-	sv, ok := iv.([]interface{})
+	var sg *signature.Signer
+	iv, ok, err = parse.GetInterfaceFromMap("signature", mv)
+	if !errs.Add(err) && ok {
+		sg, err = signature.NewFromConfig(iv)
+		errs.Add(err, "signature")
+	}
+
+	pict := New(s, l, f, e, h, p)
+	pict.Timeout = t
+	pict.CacheTTL = ttl
+	pict.Sidecar = sc
+	pict.Signer = sg
+	pict.Query = qr
+	pict.Version = version(c)
+	pict.Constraints = cs
+
+	if len(tm.names) > 0 && tok {
+		if !errs.Add(tm.check(pict)) {
+			errs.Add(tm.validate(pict))
+		}
+		pict.t = tm
+		for _, n := range tm.names {
+			pict.hosted = pict.hosted || hasSubexp(h, n)
+		}
+	}
+	if fok {
+		pict.Frame = fr
+	}
+	if aok {
+		pict.AutoOrient = ao
+	}
+	if mok {
+		pict.Metadata = mpol
+	}
+	if iok {
+		pict.Icc = imode
+	}
+
+	if len(errs) > 0 {
+		return nil, errs.Err()
+	}
+
+	return pict, nil
+}
+
+func version(c interface{}) string {
+	h := sha1.Sum([]byte(fmt.Sprint(c)))
+
+	return hex.EncodeToString(h[:4])
+}
+
+func newLoader(c interface{}) (loader.Loader, error) {
+	return loader.NewFromConfig(c)
+}
+
+func newFilters(c interface{}) ([]filter.Filter, error) {
+	var err error
+
+	sv, ok := c.([]interface{})
 	if !ok {
-		sv = []interface{}{iv}
+		sv = []interface{}{c}
 	}
 	f := make([]filter.Filter, len(sv))
+	var errs parse.Errors
 	for i, iv := range sv {
 		f[i], err = filter.NewFromConfig(iv)
 		if err != nil {
-			return nil, err
+			errs.Add(index(err, i, ok))
 		}
 	}
+	if len(errs) > 0 {
+		return nil, errs.Err()
+	}
 
-	iv, err = parse.GetRequiredInterfaceFromMap("encoder", mv)
-	if err != nil {
-		return nil, err
+	return f, nil
+}
+
+func newEncoders(c interface{}) ([]encoder.Encoder, error) {
+	var err error
+
+	sv, ok := c.([]interface{})
+	if !ok {
+		sv = []interface{}{c}
 	}
-	e, err := encoder.NewFromConfig(iv)
-	if err != nil {
-		return nil, err
+	if len(sv) == 0 {
+		return nil, errors.New("a picture must have at least one encoder")
 	}
-
-	h, _, err := parse.GetRegexpFromMap("host_pattern", mv)
-	if err != nil {
-		return nil, err
+	e := make([]encoder.Encoder, len(sv))
+	var errs parse.Errors
+	for i, iv := range sv {
+		e[i], err = encoder.NewFromConfig(iv)
+		if err != nil {
+			errs.Add(index(err, i, ok))
+		}
 	}
-	p, _, err := parse.GetRegexpFromMap("path_pattern", mv)
-	if err != nil {
-		return nil, err
+	if len(errs) > 0 {
+		return nil, errs.Err()
+	}
+
+	return e, nil
+}
+
+func index(err error, i int, ok bool) error {
+	if !ok {
+		return err
 	}
 
-	return New(s, l, f, e, h, p), nil
+	return parse.Prefix(err, parse.Index(i))
 }
 
 func NewPicturesFromConfig(c []interface{}) (Pictures, error) {
@@ -115,7 +333,7 @@ func NewPicturesFromConfig(c []interface{}) (Pictures, error) {
 	for i := range c {
 		p[i], err = NewPictureFromConfig(c[i])
 		if err != nil {
-			return nil, err
+			return nil, parse.Prefix(err, parse.Index(i))
 		}
 	}
 
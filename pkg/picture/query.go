@@ -0,0 +1,327 @@
+package picture
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ueef/mosaic/pkg/encoder"
+	"github.com/ueef/mosaic/pkg/filter"
+	"github.com/ueef/mosaic/pkg/parse"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const QueryWidth = "w"
+const QueryHeight = "h"
+const QueryFit = "fit"
+const QueryGravity = "g"
+const QueryBackground = "bg"
+const QueryUpscale = "up"
+const QueryBlur = "blur"
+const QueryQuality = "q"
+const QueryFormat = "fmt"
+
+var ErrInvalidQuery = errors.New("a query is invalid")
+
+var queryKeys = []string{QueryBackground, QueryBlur, QueryFit, QueryFormat, QueryGravity, QueryHeight, QueryQuality, QueryUpscale, QueryWidth}
+
+var fits = map[string]string{
+	filter.ResizeFit:     filter.ResizeFit,
+	filter.ResizeFill:    filter.ResizeFill,
+	filter.ResizePad:     filter.ResizePad,
+	filter.ResizeStretch: filter.ResizeStretch,
+	"contain":            filter.ResizeFit,
+	"cover":              filter.ResizeFill,
+}
+
+var gravities = []string{
+	filter.GravityCenter,
+	filter.GravityEast,
+	filter.GravityNorth,
+	filter.GravityNorthEast,
+	filter.GravityNorthWest,
+	filter.GravitySmart,
+	filter.GravitySouth,
+	filter.GravitySouthEast,
+	filter.GravitySouthWest,
+	filter.GravityWest,
+}
+
+type Query struct {
+	MaxWidth   int
+	MaxHeight  int
+	MaxBlur    float64
+	MinQuality int
+	MaxQuality int
+	Formats    []string
+}
+
+func (q Query) parse(v url.Values, p filter.Params) (map[string]string, error) {
+	o := map[string]string{}
+	for _, k := range queryKeys {
+		s, ok := p[k]
+		if !ok {
+			s = v.Get(k)
+		}
+		if s == "" {
+			continue
+		}
+
+		switch k {
+		case QueryWidth, QueryHeight:
+			m := q.MaxWidth
+			if k == QueryHeight {
+				m = q.MaxHeight
+			}
+			i, err := strconv.Atoi(s)
+			if err != nil || i <= 0 || i > m {
+				return nil, fmt.Errorf("%w: \"%s\" must be an integer in 1..%d", ErrInvalidQuery, k, m)
+			}
+			s = strconv.Itoa(i)
+		case QueryFit:
+			f, ok := fits[s]
+			if !ok {
+				return nil, fmt.Errorf("%w: a fit \"%s\" is undefined", ErrInvalidQuery, s)
+			}
+			s = f
+		case QueryGravity:
+			if !contains(gravities, s) {
+				return nil, fmt.Errorf("%w: a gravity \"%s\" is undefined", ErrInvalidQuery, s)
+			}
+		case QueryBackground:
+			s = "#" + strings.TrimPrefix(strings.ToLower(s), "#")
+		case QueryUpscale:
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return nil, fmt.Errorf("%w: \"%s\" must be a boolean", ErrInvalidQuery, k)
+			}
+			s = strconv.FormatBool(b)
+		case QueryBlur:
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil || f <= 0 || f > q.MaxBlur {
+				return nil, fmt.Errorf("%w: \"%s\" must be a number in 0..%g", ErrInvalidQuery, k, q.MaxBlur)
+			}
+			s = strconv.FormatFloat(f, 'f', -1, 64)
+		case QueryQuality:
+			i, err := strconv.Atoi(s)
+			if err != nil || i < q.MinQuality || i > q.MaxQuality {
+				return nil, fmt.Errorf("%w: \"%s\" must be an integer in %d..%d", ErrInvalidQuery, k, q.MinQuality, q.MaxQuality)
+			}
+			s = strconv.Itoa(i)
+		case QueryFormat:
+			if s == "jpg" {
+				s = encoder.TypeJpeg
+			}
+			if !contains(q.Formats, s) {
+				return nil, fmt.Errorf("%w: a format \"%s\" isn't allowed", ErrInvalidQuery, s)
+			}
+		}
+		o[k] = s
+	}
+
+	return o, nil
+}
+
+// Transform returns a copy of the picture with filters and encoders of the
+// options of a query, keeping the ones which take effect for its Key.
+func (p *Picture) Transform(query string, params filter.Params) (*Picture, error) {
+	if p.Query == nil {
+		return p, nil
+	}
+
+	v, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidQuery, err)
+	}
+
+	o, err := p.Query.parse(v, params)
+	if err != nil {
+		return nil, err
+	}
+	effective(o, p.Encoders)
+	if len(o) == 0 {
+		return p, nil
+	}
+
+	r := &Picture{}
+	*r = *p
+	r.o = o
+	r.Filters = append([]filter.Filter{}, p.Filters...)
+
+	_, w := o[QueryWidth]
+	_, h := o[QueryHeight]
+	if w || h {
+		m := map[string]interface{}{}
+		for k, n := range map[string]string{QueryFit: "mode", QueryGravity: "gravity", QueryBackground: "background"} {
+			if s, ok := o[k]; ok {
+				m[n] = s
+			}
+		}
+		m["width"], _ = strconv.Atoi(o[QueryWidth])
+		m["height"], _ = strconv.Atoi(o[QueryHeight])
+		m["upscale"] = o[QueryUpscale] == "true"
+
+		f, err := filter.New(filter.TypeResize, m)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidQuery, err)
+		}
+		r.Filters = append(r.Filters, f)
+	}
+
+	if s, ok := o[QueryBlur]; ok {
+		b, _ := strconv.ParseFloat(s, 64)
+		f, err := filter.New(filter.TypeBlur, map[string]interface{}{"radius": b})
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidQuery, err)
+		}
+		r.Filters = append(r.Filters, f)
+	}
+
+	qs, qok := o[QueryQuality]
+	if t, ok := o[QueryFormat]; ok {
+		m := map[string]interface{}{}
+		if t == encoder.TypeJpeg {
+			m["quality"] = jpegQuality(p.Encoders, qs)
+		}
+		e, err := encoder.New(t, m)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidQuery, err)
+		}
+		r.Encoders = []encoder.Encoder{e}
+	} else if qok {
+		r.Encoders = make([]encoder.Encoder, len(p.Encoders))
+		for i, e := range p.Encoders {
+			if _, ok := e.(*encoder.JpegEncoder); ok {
+				e = encoder.NewJpegEncoder(jpegQuality(nil, qs))
+			}
+			r.Encoders[i] = e
+		}
+	}
+
+	return r, nil
+}
+
+// effective drops options which leave the output as it is without them, so
+// identical renditions share a cache key. A quality is dropped later by Key
+// if the negotiated encoder isn't a jpeg one.
+func effective(o map[string]string, e []encoder.Encoder) {
+	_, w := o[QueryWidth]
+	_, h := o[QueryHeight]
+	if !w && !h {
+		delete(o, QueryFit)
+		delete(o, QueryUpscale)
+	}
+	if o[QueryFit] == filter.ResizeFit {
+		delete(o, QueryFit)
+	}
+	if f := o[QueryFit]; (f != filter.ResizeFill && f != filter.ResizePad) || o[QueryGravity] == filter.GravityCenter {
+		delete(o, QueryGravity)
+	}
+	if o[QueryFit] != filter.ResizePad {
+		delete(o, QueryBackground)
+	}
+	if o[QueryUpscale] == "false" {
+		delete(o, QueryUpscale)
+	}
+
+	t, ok := o[QueryFormat]
+	if (ok && t != encoder.TypeJpeg) || (!ok && !hasJpeg(e)) {
+		delete(o, QueryQuality)
+	}
+}
+
+func hasJpeg(e []encoder.Encoder) bool {
+	for i := range e {
+		if _, ok := e[i].(*encoder.JpegEncoder); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func queryKey(o map[string]string, e encoder.Encoder) string {
+	k := make([]string, 0, len(o))
+	for n, s := range o {
+		if _, ok := e.(*encoder.JpegEncoder); n == QueryQuality && !ok {
+			continue
+		}
+		k = append(k, n+"="+s)
+	}
+	sort.Strings(k)
+
+	return strings.Join(k, ",")
+}
+
+func jpegQuality(e []encoder.Encoder, q string) int {
+	if i, err := strconv.Atoi(q); err == nil {
+		return i
+	}
+	for i := range e {
+		if j, ok := e[i].(*encoder.JpegEncoder); ok {
+			return j.Quality
+		}
+	}
+
+	return 90
+}
+
+func NewQuery(maxWidth, maxHeight int) *Query {
+	return &Query{
+		MaxWidth:   maxWidth,
+		MaxHeight:  maxHeight,
+		MinQuality: 1,
+		MaxQuality: 100,
+	}
+}
+
+func NewQueryFromMap(m map[string]interface{}) (*Query, error) {
+	w, err := parse.GetRequiredIntFromMap("max_width", m)
+	if err != nil {
+		return nil, err
+	}
+	h, err := parse.GetRequiredIntFromMap("max_height", m)
+	if err != nil {
+		return nil, err
+	}
+	if w <= 0 || h <= 0 {
+		return nil, errors.New("values of keys \"max_width\" and \"max_height\" must be positive")
+	}
+	q := NewQuery(w, h)
+
+	q.MaxBlur, _, err = parse.GetFloatFromMap("max_blur", m)
+	if err != nil {
+		return nil, err
+	}
+
+	mn, ok, err := parse.GetIntFromMap("min_quality", m)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		q.MinQuality = mn
+	}
+	mx, ok, err := parse.GetIntFromMap("max_quality", m)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		q.MaxQuality = mx
+	}
+	if q.MinQuality < 1 || q.MaxQuality > 100 || q.MinQuality > q.MaxQuality {
+		return nil, errors.New("values of keys \"min_quality\" and \"max_quality\" must be within 1..100")
+	}
+
+	q.Formats, _, err = parse.GetStringsFromMap("formats", m)
+	if err != nil {
+		return nil, err
+	}
+	for i := range q.Formats {
+		if _, err = encoder.New(q.Formats[i], map[string]interface{}{"quality": 90}); err != nil {
+			return nil, err
+		}
+	}
+
+	return q, nil
+}
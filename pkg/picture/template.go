@@ -0,0 +1,365 @@
+package picture
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ueef/mosaic/pkg/filter"
+	"github.com/ueef/mosaic/pkg/parse"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const resolvedLimit = 256
+const sampleLimit = 3
+
+var ErrInvalidParam = errors.New("a parameter is invalid")
+
+var reference = regexp.MustCompile(`\$\{(\w+)\}`)
+
+type Constraint struct {
+	Values  []string
+	Min     *float64
+	Max     *float64
+	Pattern *regexp.Regexp
+}
+
+func (c Constraint) Allows(v string) bool {
+	if c.Values != nil {
+		for i := range c.Values {
+			if c.Values[i] == v {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if c.Min != nil || c.Max != nil {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || (c.Min != nil && f < *c.Min) || (c.Max != nil && f > *c.Max) {
+			return false
+		}
+	}
+
+	return c.Pattern == nil || c.Pattern.MatchString(v)
+}
+
+// samples returns a few values the constraint allows, if it can find any.
+func (c Constraint) samples() []string {
+	var vs []string
+	switch {
+	case c.Values != nil:
+		vs = c.Values
+	case c.Min != nil && c.Max != nil:
+		vs = formatFloats(*c.Max, *c.Min, math.Round((*c.Min+*c.Max)/2))
+	case c.Min != nil:
+		vs = formatFloats(*c.Min, *c.Min+1)
+	case c.Max != nil:
+		vs = formatFloats(*c.Max, *c.Max-1)
+	default:
+		vs = []string{"1", "0", "true", "a"}
+	}
+
+	r := make([]string, 0, len(vs))
+	for _, v := range vs {
+		if c.Allows(v) {
+			r = append(r, v)
+		}
+	}
+
+	return r
+}
+
+type template struct {
+	m       sync.Mutex
+	names   []string
+	refs    map[string][]string
+	loader  interface{}
+	filter  interface{}
+	encoder interface{}
+	cache   map[string]*Picture
+}
+
+// add records references of a config under a key of a picture, telling if
+// there are any. Values of a loader's "replace" are regexp replacement
+// templates with the same ${name} syntax and are never taken as references.
+func (t *template) add(k string, c interface{}) bool {
+	ok := false
+	walk(c, []string{k}, k == "loader", func(s string, path []string) {
+		for _, m := range reference.FindAllStringSubmatch(s, -1) {
+			ok = true
+			if t.refs == nil {
+				t.refs = map[string][]string{}
+			}
+			if _, found := t.refs[m[1]]; !found {
+				t.refs[m[1]] = path
+				t.names = append(t.names, m[1])
+			}
+		}
+	})
+	sort.Strings(t.names)
+
+	return ok
+}
+
+func (t *template) check(p *Picture) error {
+	for _, n := range t.names {
+		if !hasSubexp(p.HostPattern, n) && !hasSubexp(p.PathPattern, n) {
+			return &parse.Error{Path: t.refs[n], Err: fmt.Errorf("a parameter \"%s\" isn't a named group of the host or path pattern", n)}
+		}
+		if _, ok := p.Constraints[n]; !ok {
+			return &parse.Error{Path: t.refs[n], Err: fmt.Errorf("a parameter \"%s\" must be constrained in \"params\"", n)}
+		}
+	}
+
+	return nil
+}
+
+// validate builds templated configs with a few sets of parameters allowed by
+// the constraints at parse time, failing if none of them builds. Configs are
+// left to be checked when resolved if there are no such parameters.
+func (t *template) validate(p *Picture) error {
+	l := 1
+	ss := make([][]string, len(t.names))
+	for i, n := range t.names {
+		ss[i] = p.Constraints[n].samples()
+		if len(ss[i]) == 0 {
+			return nil
+		}
+		if len(ss[i]) > l {
+			l = len(ss[i])
+		}
+	}
+
+	var err error
+	for j := 0; j < min(l, sampleLimit); j++ {
+		params := filter.Params{}
+		for i, n := range t.names {
+			params[n] = ss[i][min(j, len(ss[i])-1)]
+		}
+
+		_, err = t.build(p, params)
+		if err == nil {
+			return nil
+		}
+	}
+
+	return err
+}
+
+func (t *template) build(p *Picture, params filter.Params) (*Picture, error) {
+	var err error
+
+	r := &Picture{}
+	*r = *p
+	r.t = nil
+	if t.loader != nil {
+		r.Loader, err = newLoader(substitute(t.loader, params, true))
+		if err != nil {
+			return nil, parse.Prefix(err, "loader")
+		}
+	}
+	if t.filter != nil {
+		r.Filters, err = newFilters(substitute(t.filter, params, false))
+		if err != nil {
+			return nil, parse.Prefix(err, "filter")
+		}
+	}
+	if t.encoder != nil {
+		r.Encoders, err = newEncoders(substitute(t.encoder, params, false))
+		if err != nil {
+			return nil, parse.Prefix(err, "encoder")
+		}
+	}
+
+	return r, nil
+}
+
+func (p *Picture) Resolve(params filter.Params) (*Picture, error) {
+	t := p.t
+	if t == nil {
+		return p, nil
+	}
+
+	k := make([]string, len(t.names))
+	for i, n := range t.names {
+		v, ok := params[n]
+		if !ok {
+			return nil, fmt.Errorf("%w: \"%s\" is missing", ErrInvalidParam, n)
+		}
+		if !p.Constraints[n].Allows(v) {
+			return nil, fmt.Errorf("%w: \"%s\" isn't allowed for \"%s\"", ErrInvalidParam, v, n)
+		}
+		k[i] = n + "=" + v
+	}
+	key := strings.Join(k, "&")
+
+	t.m.Lock()
+	r, ok := t.cache[key]
+	t.m.Unlock()
+	if ok {
+		return r, nil
+	}
+
+	r, err := t.build(p, params)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidParam, err)
+	}
+
+	t.m.Lock()
+	if t.cache == nil || len(t.cache) >= resolvedLimit {
+		t.cache = map[string]*Picture{}
+	}
+	t.cache[key] = r
+	t.m.Unlock()
+
+	return r, nil
+}
+
+func newConstraints(m map[string]interface{}) (map[string]Constraint, error) {
+	cs := make(map[string]Constraint, len(m))
+	for n := range m {
+		cm, err := parse.GetRequiredMapFromMap(n, m)
+		if err != nil {
+			return nil, err
+		}
+
+		c := Constraint{}
+		sv, ok, err := parse.GetSliceOfInterfacesFromMap("values", cm)
+		if err != nil {
+			return nil, parse.Prefix(err, n)
+		}
+		if ok {
+			c.Values = make([]string, len(sv))
+			for i := range sv {
+				c.Values[i] = fmt.Sprint(sv[i])
+			}
+		}
+
+		min, ok, err := parse.GetFloatFromMap("min", cm)
+		if err != nil {
+			return nil, parse.Prefix(err, n)
+		}
+		if ok {
+			c.Min = &min
+		}
+
+		max, ok, err := parse.GetFloatFromMap("max", cm)
+		if err != nil {
+			return nil, parse.Prefix(err, n)
+		}
+		if ok {
+			c.Max = &max
+		}
+
+		c.Pattern, _, err = parse.GetRegexpFromMap("pattern", cm)
+		if err != nil {
+			return nil, parse.Prefix(err, n)
+		}
+
+		if c.Values == nil && c.Min == nil && c.Max == nil && c.Pattern == nil {
+			return nil, fmt.Errorf("a constraint of a parameter \"%s\" must have values, min, max or a pattern", n)
+		}
+
+		cs[n] = c
+	}
+
+	return cs, nil
+}
+
+func substitute(c interface{}, p filter.Params, loader bool) interface{} {
+	switch v := c.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k := range v {
+			if loader && k == "replace" {
+				m[k] = v[k]
+			} else {
+				m[k] = substitute(v[k], p, loader)
+			}
+		}
+		parse.Alias(m, v)
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i := range v {
+			s[i] = substitute(v[i], p, loader)
+		}
+		return s
+	case string:
+		if m := reference.FindStringSubmatch(v); m != nil && m[0] == v {
+			return typed(p[m[1]])
+		}
+		return reference.ReplaceAllStringFunc(v, func(s string) string {
+			return p[s[2:len(s)-1]]
+		})
+	}
+
+	return c
+}
+
+func typed(s string) interface{} {
+	if i, err := strconv.Atoi(s); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	if b, err := strconv.ParseBool(s); err == nil {
+		return b
+	}
+
+	return s
+}
+
+func walk(c interface{}, path []string, loader bool, f func(s string, path []string)) {
+	switch v := c.(type) {
+	case map[string]interface{}:
+		for k := range v {
+			if !loader || k != "replace" {
+				walk(v[k], append(path[:len(path):len(path)], k), loader, f)
+			}
+		}
+	case []interface{}:
+		for i := range v {
+			walk(v[i], append(path[:len(path):len(path)], parse.Index(i)), loader, f)
+		}
+	case string:
+		f(v, path)
+	}
+}
+
+func hasSubexp(r *regexp.Regexp, n string) bool {
+	return r != nil && contains(r.SubexpNames(), n)
+}
+
+func contains(s []string, v string) bool {
+	for i := range s {
+		if s[i] == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+func formatFloats(f ...float64) []string {
+	s := make([]string, len(f))
+	for i := range f {
+		s[i] = strconv.FormatFloat(f[i], 'f', -1, 64)
+	}
+
+	return s
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
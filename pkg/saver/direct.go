@@ -12,10 +12,13 @@ type Direct struct {
 }
 
 func (s Direct) Save(path string, data []byte) error {
-	p := s.GetFilePath(path)
+	p, err := s.GetFilePath(path)
+	if err != nil {
+		return err
+	}
 	d := filepath.Dir(p)
 
-	err := os.MkdirAll(d, 0755)
+	err = os.MkdirAll(d, 0755)
 	if err != nil {
 		return err
 	}
@@ -28,8 +31,17 @@ func (s Direct) Save(path string, data []byte) error {
 	return nil
 }
 
-func (s Direct) GetFilePath(path string) string {
-	return s.Dir + path
+func (s Direct) Lookup(path string) ([]byte, bool, error) {
+	p, err := s.GetFilePath(path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return lookup(p)
+}
+
+func (s Direct) GetFilePath(path string) (string, error) {
+	return Contain(s.Dir, path)
 }
 
 func NewDirect(dir string) *Direct {
@@ -46,3 +58,15 @@ func NewDirectFromMap(m map[string]interface{}) (*Direct, error) {
 
 	return NewDirect(dir), nil
 }
+
+func lookup(p string) ([]byte, bool, error) {
+	b, err := ioutil.ReadFile(p)
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	return b, true, nil
+}
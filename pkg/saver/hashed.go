@@ -33,6 +33,15 @@ func (s Hashed) Save(path string, data []byte) error {
 	return nil
 }
 
+func (s Hashed) Lookup(path string) ([]byte, bool, error) {
+	p, err := s.GetFilePath(path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return lookup(p)
+}
+
 func (s Hashed) GetFilePath(path string) (string, error) {
 	hr := md5.New()
 	_, err := hr.Write([]byte(path))
@@ -41,7 +50,7 @@ func (s Hashed) GetFilePath(path string) (string, error) {
 	}
 	hs := base64.RawURLEncoding.EncodeToString(hr.Sum(nil))
 
-	return s.Dir + "/" + hs + ".png", nil
+	return Contain(s.Dir, hs+".png")
 }
 
 func NewHashed(dir string) *Hashed {
@@ -3,16 +3,25 @@ package saver
 import (
 	"errors"
 	"github.com/ueef/mosaic/pkg/parse"
+	"path/filepath"
+	"strings"
 )
 
 const TypeNull = "null"
 const TypeDirect = "direct"
 const TypeHashed = "hashed"
 
+var ErrOutsideDir = errors.New("a path leads outside of the dir")
+
 type Saver interface {
 	Save(path string, data []byte) error
 }
 
+type Lookuper interface {
+	Saver
+	Lookup(path string) ([]byte, bool, error)
+}
+
 func New(t string, m map[string]interface{}) (s Saver, err error) {
 	switch t {
 	case TypeNull:
@@ -42,5 +51,22 @@ func NewFromConfig(c interface{}) (s Saver, err error) {
 		return nil, err
 	}
 
-	return New(t, m)
+	v, err := New(t, m)
+	if err != nil {
+		return nil, parse.Nest(err, "config")
+	}
+
+	return v, nil
+}
+
+// Contain joins a dir and a path, failing if the cleaned result isn't within
+// the dir, e.g. for a path with ".." segments taken from a url.
+func Contain(dir, path string) (string, error) {
+	p := filepath.Join(dir, path)
+	r, err := filepath.Rel(filepath.Clean(dir), p)
+	if err != nil || r == ".." || strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+		return "", ErrOutsideDir
+	}
+
+	return p, nil
 }
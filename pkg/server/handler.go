@@ -0,0 +1,93 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ueef/mosaic/pkg/dispatcher"
+	"github.com/ueef/mosaic/pkg/loader"
+	"github.com/ueef/mosaic/pkg/picture"
+	"github.com/ueef/mosaic/pkg/signature"
+	"net/http"
+	"strconv"
+)
+
+type Handler struct {
+	d *dispatcher.Dispatcher
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", http.MethodGet+", "+http.MethodHead)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	c, err := h.d.DispatchRequest(r.Context(), dispatcher.Request{
+		Host:   r.Host,
+		Path:   r.URL.Path,
+		Accept: r.Header.Get("Accept"),
+		Query:  r.URL.RawQuery,
+	})
+	if err != nil {
+		h.error(w, err)
+		return
+	}
+
+	res := <-c
+	if !res.IsSuccessful() {
+		h.error(w, res.Err)
+		return
+	}
+
+	if len(res.Pict.Encoders) > 1 {
+		w.Header().Add("Vary", "Accept")
+	}
+	w.Header().Set("Content-Type", res.Enc.GetMime())
+	w.Header().Set("Content-Length", strconv.Itoa(len(res.Buff)))
+	w.WriteHeader(http.StatusOK)
+
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(res.Buff)
+	}
+}
+
+func (h *Handler) error(w http.ResponseWriter, err error) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+
+	// details of an error are for logs only, as they may expose paths
+	fmt.Println(err)
+
+	s := status(err)
+	http.Error(w, http.StatusText(s), s)
+}
+
+func status(err error) int {
+	var le *dispatcher.LoadError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, signature.ErrInvalid), errors.Is(err, signature.ErrExpired):
+		return http.StatusForbidden
+	case errors.Is(err, picture.ErrInvalidParam), errors.Is(err, picture.ErrInvalidQuery):
+		return http.StatusBadRequest
+	case errors.Is(err, dispatcher.ErrTooLarge):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, dispatcher.ErrStopped):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, picture.ErrNotMatched), errors.Is(err, loader.ErrNotFound):
+		return http.StatusNotFound
+	case errors.As(err, &le):
+		return http.StatusBadGateway
+	}
+
+	return http.StatusInternalServerError
+}
+
+func NewHandler(d *dispatcher.Dispatcher) *Handler {
+	return &Handler{
+		d: d,
+	}
+}
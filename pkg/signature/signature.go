@@ -0,0 +1,222 @@
+package signature
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/ueef/mosaic/pkg/parse"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const AlgorithmSha1 = "sha1"
+const AlgorithmSha256 = "sha256"
+const AlgorithmSha512 = "sha512"
+
+const LocationQuery = "query"
+const LocationPath = "path"
+
+const ParamSignature = "signature"
+const ParamExpires = "expires"
+
+var ErrInvalid = errors.New("the signature is invalid")
+var ErrExpired = errors.New("the signature is expired")
+
+type Signer struct {
+	keys     [][]byte
+	hash     func() hash.Hash
+	location string
+}
+
+// Strip returns a path without a signature segment, the one pictures are
+// matched against.
+func (s Signer) Strip(path string) string {
+	if s.location != LocationPath {
+		return path
+	}
+
+	_, p := splitPath(path)
+
+	return p
+}
+
+// Verify checks a signature of a path and a raw query and returns the path
+// without the signature.
+func (s Signer) Verify(path, query string) (string, error) {
+	q, err := url.ParseQuery(query)
+	if err != nil {
+		return "", ErrInvalid
+	}
+
+	var sig string
+	if s.location == LocationPath {
+		sig, path = splitPath(path)
+	} else {
+		sig = q.Get(ParamSignature)
+		q.Del(ParamSignature)
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || len(b) == 0 {
+		return "", ErrInvalid
+	}
+
+	m := message(path, q)
+	ok := false
+	for _, k := range s.keys {
+		if hmac.Equal(b, s.sign(k, m)) {
+			ok = true
+			break
+		}
+	}
+	if !ok {
+		return "", ErrInvalid
+	}
+
+	if e := q.Get(ParamExpires); e != "" {
+		t, err := strconv.ParseInt(e, 10, 64)
+		if err != nil {
+			return "", ErrInvalid
+		}
+		if time.Now().Unix() > t {
+			return "", ErrExpired
+		}
+	}
+
+	return path, nil
+}
+
+// Sign returns a signed url of a path and a query with the first key. A zero
+// expiration time makes the url valid forever.
+func (s Signer) Sign(path string, query url.Values, expires time.Time) string {
+	q := url.Values{}
+	for k, v := range query {
+		q[k] = v
+	}
+	q.Del(ParamSignature)
+	if !expires.IsZero() {
+		q.Set(ParamExpires, strconv.FormatInt(expires.Unix(), 10))
+	}
+
+	sig := base64.RawURLEncoding.EncodeToString(s.sign(s.keys[0], message(path, q)))
+	if s.location == LocationPath {
+		path = "/" + sig + path
+	} else {
+		q.Set(ParamSignature, sig)
+	}
+
+	if len(q) == 0 {
+		return path
+	}
+
+	return path + "?" + q.Encode()
+}
+
+func (s Signer) sign(k []byte, m string) []byte {
+	h := hmac.New(s.hash, k)
+	_, _ = h.Write([]byte(m))
+
+	return h.Sum(nil)
+}
+
+func message(path string, q url.Values) string {
+	return path + "?" + q.Encode()
+}
+
+func splitPath(path string) (string, string) {
+	p := strings.TrimPrefix(path, "/")
+	i := strings.IndexByte(p, '/')
+	if i < 0 {
+		return p, "/"
+	}
+
+	return p[:i], p[i:]
+}
+
+func New(keys []string, algorithm, location string) (*Signer, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("a signer must have at least one key")
+	}
+
+	s := &Signer{
+		keys:     make([][]byte, len(keys)),
+		location: location,
+	}
+	for i := range keys {
+		if keys[i] == "" {
+			return nil, errors.New("a key of a signer must not be empty")
+		}
+		s.keys[i] = []byte(keys[i])
+	}
+
+	switch algorithm {
+	case AlgorithmSha1:
+		s.hash = sha1.New
+	case AlgorithmSha256, "":
+		s.hash = sha256.New
+	case AlgorithmSha512:
+		s.hash = sha512.New
+	default:
+		return nil, fmt.Errorf("an algorithm \"%s\" is undefined", algorithm)
+	}
+
+	switch location {
+	case LocationQuery, LocationPath:
+	case "":
+		s.location = LocationQuery
+	default:
+		return nil, fmt.Errorf("a location of a signature \"%s\" is undefined", location)
+	}
+
+	return s, nil
+}
+
+func NewFromMap(m map[string]interface{}) (*Signer, error) {
+	var keys []string
+	sv, ok, err := parse.GetSliceOfInterfacesFromMap("keys", m)
+	if err != nil {
+		return nil, err
+	}
+	for i, v := range sv {
+		k, ok := v.(string)
+		if !ok {
+			return nil, parse.Prefix(fmt.Errorf("a value must be a string, got %T", v), "keys", parse.Index(i))
+		}
+		keys = append(keys, k)
+	}
+	if !ok {
+		k, err := parse.GetRequiredStringFromMap("key", m)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+
+	a, _, err := parse.GetStringFromMap("algorithm", m)
+	if err != nil {
+		return nil, err
+	}
+
+	l, _, err := parse.GetStringFromMap("location", m)
+	if err != nil {
+		return nil, err
+	}
+
+	return New(keys, a, l)
+}
+
+func NewFromConfig(c interface{}) (*Signer, error) {
+	m, ok := c.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("a config must be of the type map[string]interface{}")
+	}
+
+	return NewFromMap(m)
+}
@@ -0,0 +1,147 @@
+package signature
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func split(u string) (string, string) {
+	if i := strings.IndexByte(u, '?'); i >= 0 {
+		return u[:i], u[i+1:]
+	}
+
+	return u, ""
+}
+
+func TestSignVerify(t *testing.T) {
+	for _, algorithm := range []string{"", AlgorithmSha1, AlgorithmSha256, AlgorithmSha512} {
+		for _, location := range []string{LocationQuery, LocationPath} {
+			s, err := New([]string{"key"}, algorithm, location)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, q := range []url.Values{nil, {"w": {"100"}, "fmt": {"webp"}}} {
+				p, rq := split(s.Sign("/img/a.jpg", q, time.Time{}))
+				if location == LocationPath && s.Strip(p) != "/img/a.jpg" {
+					t.Errorf("%s %s: Strip(%q) = %q", algorithm, location, p, s.Strip(p))
+				}
+
+				got, err := s.Verify(p, rq)
+				if err != nil || got != "/img/a.jpg" {
+					t.Errorf("%s %s: Verify(%q, %q) = %q, %v", algorithm, location, p, rq, got, err)
+				}
+			}
+		}
+	}
+}
+
+func TestVerifyTampered(t *testing.T) {
+	s, err := New([]string{"key"}, "", LocationQuery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, q := split(s.Sign("/img/a.jpg", url.Values{"w": {"100"}}, time.Time{}))
+
+	for _, tc := range []struct {
+		name  string
+		path  string
+		query string
+	}{
+		{"path", "/img/b.jpg", q},
+		{"param", p, strings.Replace(q, "w=100", "w=200", 1)},
+		{"added param", p, q + "&h=10"},
+		{"no signature", p, "w=100"},
+		{"bad encoding", p, "w=100&signature=%%%"},
+		{"bad query", p, "%zz"},
+	} {
+		if _, err := s.Verify(tc.path, tc.query); err != ErrInvalid {
+			t.Errorf("%s: got %v, want %v", tc.name, err, ErrInvalid)
+		}
+	}
+
+	o, err := New([]string{"other"}, "", LocationQuery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := o.Verify(p, q); err != ErrInvalid {
+		t.Errorf("other key: got %v, want %v", err, ErrInvalid)
+	}
+}
+
+func TestVerifyExpires(t *testing.T) {
+	s, err := New([]string{"key"}, "", LocationQuery)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		expires time.Time
+		want    error
+	}{
+		{time.Now().Add(time.Hour), nil},
+		{time.Now().Add(-time.Hour), ErrExpired},
+	} {
+		p, q := split(s.Sign("/a.jpg", nil, tc.expires))
+		if _, err := s.Verify(p, q); err != tc.want {
+			t.Errorf("expires %s: got %v, want %v", tc.expires, err, tc.want)
+		}
+
+		// an expiration time is signed as well
+		q = strings.Replace(q, "expires=", "expires=9", 1)
+		if _, err := s.Verify(p, q); err != ErrInvalid {
+			t.Errorf("expires %s changed: got %v, want %v", tc.expires, err, ErrInvalid)
+		}
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	old, err := New([]string{"old"}, "", LocationQuery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotated, err := New([]string{"new", "old"}, "", LocationQuery)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, q := split(old.Sign("/a.jpg", nil, time.Time{}))
+	if _, err := rotated.Verify(p, q); err != nil {
+		t.Errorf("a url signed with a former key: got %v", err)
+	}
+
+	p, q = split(rotated.Sign("/a.jpg", nil, time.Time{}))
+	if _, err := old.Verify(p, q); err != ErrInvalid {
+		t.Errorf("a url signed with the first key: got %v, want %v", err, ErrInvalid)
+	}
+	if _, err := rotated.Verify(p, q); err != nil {
+		t.Errorf("a url signed with the first key: got %v", err)
+	}
+}
+
+func TestNewFromMap(t *testing.T) {
+	for _, tc := range []struct {
+		m    map[string]interface{}
+		want string
+	}{
+		{map[string]interface{}{"key": "k"}, ""},
+		{map[string]interface{}{"keys": []interface{}{"k1", "k2"}}, ""},
+		{map[string]interface{}{}, "key: a key is undefined"},
+		{map[string]interface{}{"keys": []interface{}{"secret", 5}}, "keys[1]: a value must be a string, got int"},
+		{map[string]interface{}{"key": "k", "algorithm": "md5"}, "an algorithm \"md5\" is undefined"},
+	} {
+		_, err := NewFromMap(tc.m)
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if got != tc.want {
+			t.Errorf("NewFromMap(%v) = %q, want %q", tc.m, got, tc.want)
+		}
+		if strings.Contains(got, "secret") {
+			t.Errorf("NewFromMap(%v) exposes a key: %q", tc.m, got)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	_ "golang.org/x/image/webp"
 	"image"
 	"image/draw"
 	_ "image/gif"
@@ -11,70 +12,64 @@ import (
 )
 
 func Rotate270(i image.Image) image.Image {
-	src := ConvertToRGBA(i)
-	b := src.Bounds()
-	dst := image.NewRGBA(image.Rectangle{
-		Min: image.Point{
-			X: b.Min.Y,
-			Y: b.Min.X,
-		},
-		Max: image.Point{
-			X: b.Max.Y,
-			Y: b.Max.X,
-		},
+	return transform(i, true, func(x, y, w, h int) (int, int) {
+		return y, w - x - 1
 	})
-	for y := b.Min.Y; y < b.Max.Y; y++ {
-		for x := b.Min.X; x < b.Max.X; x++ {
-			s := y*src.Stride + x*4
-			d := (b.Max.X-x-1)*dst.Stride + y*4
-			copy(dst.Pix[d:d+4], src.Pix[s:s+4])
-		}
-	}
-
-	return dst
 }
 
 func Rotate180(i image.Image) image.Image {
-	src := ConvertToRGBA(i)
-	b := src.Bounds()
-	dst := image.NewRGBA(image.Rectangle{
-		Min: image.Point{
-			X: b.Min.Y,
-			Y: b.Min.X,
-		},
-		Max: image.Point{
-			X: b.Max.Y,
-			Y: b.Max.X,
-		},
+	return transform(i, false, func(x, y, w, h int) (int, int) {
+		return w - x - 1, h - y - 1
 	})
-	for y := b.Min.Y; y < b.Max.Y; y++ {
-		for x := b.Min.X; x < b.Max.X; x++ {
-			s := y*src.Stride + x*4
-			d := (b.Max.X-x-1)*dst.Stride + (b.Max.Y-y-1)*4
-			copy(dst.Pix[d:d+4], src.Pix[s:s+4])
-		}
-	}
-
-	return src
 }
 
 func Rotate90(i image.Image) image.Image {
+	return transform(i, true, func(x, y, w, h int) (int, int) {
+		return h - y - 1, x
+	})
+}
+
+func FlipHorizontal(i image.Image) image.Image {
+	return transform(i, false, func(x, y, w, h int) (int, int) {
+		return w - x - 1, y
+	})
+}
+
+func FlipVertical(i image.Image) image.Image {
+	return transform(i, false, func(x, y, w, h int) (int, int) {
+		return x, h - y - 1
+	})
+}
+
+func Transpose(i image.Image) image.Image {
+	return transform(i, true, func(x, y, w, h int) (int, int) {
+		return y, x
+	})
+}
+
+func Transverse(i image.Image) image.Image {
+	return transform(i, true, func(x, y, w, h int) (int, int) {
+		return h - y - 1, w - x - 1
+	})
+}
+
+func transform(i image.Image, swap bool, f func(x, y, w, h int) (int, int)) image.Image {
 	src := ConvertToRGBA(i)
 	b := src.Bounds()
-	dst := image.NewRGBA(image.Rectangle{
-		Min: image.Point{
-			X: b.Min.Y,
-			Y: b.Min.X,
-		},
-		Max: image.Point{
-			X: b.Max.Y,
-			Y: b.Max.X,
-		},
-	})
-	for y := b.Min.Y; y < b.Max.Y; y++ {
-		for x := b.Min.X; x < b.Max.X; x++ {
-			s := y*src.Stride + x*4
-			d := x*dst.Stride + (b.Max.Y-y-1)*4
+	w, h := b.Dx(), b.Dy()
+
+	var dst *image.RGBA
+	if swap {
+		dst = image.NewRGBA(image.Rect(0, 0, h, w))
+	} else {
+		dst = image.NewRGBA(image.Rect(0, 0, w, h))
+	}
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			dx, dy := f(x, y, w, h)
+			s := src.PixOffset(b.Min.X+x, b.Min.Y+y)
+			d := dst.PixOffset(dx, dy)
 			copy(dst.Pix[d:d+4], src.Pix[s:s+4])
 		}
 	}